@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
 	"arc/controller"
 	"arc/files/file_fs"
@@ -14,6 +17,21 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(headless(command, os.Args[2:]))
+		}
+		if os.Args[1] == "trash" {
+			os.Exit(trash(os.Args[2:]))
+		}
+		if os.Args[1] == "verify" {
+			os.Exit(verify(os.Args[2:]))
+		}
+		if os.Args[1] == "init" {
+			os.Exit(initArchives(os.Args[2:]))
+		}
+	}
+
 	var err any
 	var stack []byte
 
@@ -34,12 +52,26 @@ func main() {
 		}()
 	}
 
+	sim := flag.Bool("sim", false, "simulate archives with hashing")
+	sim2 := flag.Bool("sim2", false, "simulate archives")
+	policyName := flag.String("policy", m.OriginWins.String(), "resolution policy: origin-wins, newest-wins or majority-wins")
+	dryRun := flag.Bool("dry-run", false, "review the planned operations before they touch the disk")
+	options := fsFlags(flag.CommandLine)
+	flag.Parse()
+
+	policy, parseErr := m.ParsePolicy(*policyName)
+	if parseErr != nil {
+		fmt.Fprintln(os.Stderr, parseErr)
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
 	var paths []m.Root
-	if len(os.Args) >= 1 && (os.Args[1] == "-sim" || os.Args[1] == "-sim2") {
+	if *sim || *sim2 {
 		paths = []m.Root{"origin", "copy 1", "copy 2"}
 	} else {
-		paths = make([]m.Root, len(os.Args)-1)
-		for i, path := range os.Args[1:] {
+		paths = make([]m.Root, flag.NArg())
+		for i, path := range flag.Args() {
 			path, err := file_fs.AbsPath(path)
 			paths[i] = m.Root(path)
 			if err != nil {
@@ -59,17 +91,66 @@ func main() {
 
 	var fs m.FS
 
-	if len(os.Args) >= 1 && os.Args[1] == "-sim" {
+	if *sim {
 		fs = mock_fs.NewFs(events)
 		mock_fs.Scan = true
-	} else if len(os.Args) >= 1 && os.Args[1] == "-sim2" {
+	} else if *sim2 {
 		fs = mock_fs.NewFs(events)
 	} else {
-		fs = file_fs.NewFs(events, lc)
+		fs = file_fs.NewFs(events, lc, options())
 	}
 
-	err, stack = controller.Run(fs, renderer, events, paths)
+	err, stack = controller.Run(fs, renderer, events, paths, policy, *dryRun)
 
 	renderer.Quit()
 	lc.Stop()
 }
+
+// fsFlags adds the flags of the file system to the flag set.
+func fsFlags(flags *flag.FlagSet) func() file_fs.Options {
+	days := flags.Int("trash-days", 0, "purge trashed files older than the number of days; 0 keeps them")
+	bytes := flags.Uint64("trash-bytes", 0, "purge the oldest trashed files beyond the number of bytes per root; 0 keeps them")
+	hddWorkers := flags.Int("hdd-workers", 1, "files hashed at once per spinning disk")
+	ssdWorkers := flags.Int("ssd-workers", 4, "files hashed at once per solid state disk")
+	lazy := flags.Bool("lazy", false, "hash only files whose size matches another file")
+	algorithm := file_fs.SHA256
+	flags.Func("hash", "hash algorithm: sha256 or dualcrc, a much faster one for bulk comparison (default sha256)", func(name string) (err error) {
+		algorithm, err = file_fs.ParseHashAlgorithm(name)
+		return err
+	})
+	catalog := catalogFlag(flags)
+	hashOrder := file_fs.ExtentOrder
+	flags.Func("hash-order", "order to hash files in: walk, inode or extent (default extent)", func(name string) (err error) {
+		hashOrder, err = file_fs.ParseHashOrder(name)
+		return err
+	})
+	return func() file_fs.Options {
+		return file_fs.Options{
+			Retention:     m.Retention{Days: *days, Bytes: *bytes},
+			HDDWorkers:    *hddWorkers,
+			SSDWorkers:    *ssdWorkers,
+			HashOrder:     hashOrder,
+			Lazy:          *lazy,
+			HashAlgorithm: algorithm,
+			Catalog:       catalog(),
+		}
+	}
+}
+
+// catalogFlag adds the repeatable -catalog flag and returns the absolute roots given.
+func catalogFlag(flags *flag.FlagSet) func() []m.Root {
+	paths := []string{}
+	flags.Func("catalog", "keep the hash store of the root in "+filepath.Join(file_fs.DataDir(), "catalog")+" instead of the root; repeatable, read-only roots always do", func(path string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	return func() []m.Root {
+		roots := []m.Root{}
+		for _, path := range paths {
+			if path, err := file_fs.AbsPath(path); err == nil {
+				roots = append(roots, m.Root(path))
+			}
+		}
+		return roots
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"arc/controller"
+	"arc/files/file_fs"
+	"arc/lifecycle"
+	m "arc/model"
+	"arc/stream"
+)
+
+const (
+	exitSynced    = 0
+	exitDivergent = 1
+	exitError     = 2
+	exitUsage     = 3
+)
+
+var commands = map[string]controller.Command{
+	"status": controller.Status,
+	"plan":   controller.Plan,
+	"sync":   controller.Sync,
+	"apply":  controller.Apply,
+}
+
+// headless runs a subcommand without the terminal UI and returns the exit code.
+func headless(command controller.Command, args []string) int {
+	log.SetOutput(io.Discard)
+
+	flags := flag.NewFlagSet("arc "+command.String(), flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	policyName := flags.String("policy", m.OriginWins.String(), "resolution policy: origin-wins, newest-wins or majority-wins")
+	dryRun := flags.Bool("dry-run", false, "print the plan without applying it")
+	planPath := flags.String("out", "", "save the plan to the JSON file for arc apply")
+	scriptPath := flags.String("script", "", "save the plan as a POSIX shell script")
+	options := fsFlags(flags)
+	flags.Usage = func() {
+		if command == controller.Apply {
+			fmt.Fprintln(flags.Output(), "Usage: arc apply [flags] plan.json")
+		} else {
+			fmt.Fprintf(flags.Output(), "Usage: arc %s [flags] origin copy...\n", command)
+		}
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), "Exit codes: 0 in sync or applied, 1 divergent, 2 errors, 3 usage")
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	policy, err := m.ParsePolicy(*policyName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *dryRun && command == controller.Sync {
+		command = controller.Plan
+	}
+
+	lc := lifecycle.New()
+	events := stream.NewStream[m.Event]("headless")
+	var report *controller.Report
+	var panicErr any
+	if command == controller.Apply {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return exitUsage
+		}
+		plan, err := file_fs.ReadPlan(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		report, panicErr = controller.ApplyPlan(file_fs.NewFs(events, lc, options()), events, plan)
+	} else {
+		if flags.NArg() < 2 {
+			flags.Usage()
+			return exitUsage
+		}
+		roots := make([]m.Root, flags.NArg())
+		for i, path := range flags.Args() {
+			path, err := file_fs.AbsPath(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitUsage
+			}
+			roots[i] = m.Root(path)
+		}
+		report, panicErr = controller.Headless(file_fs.NewFs(events, lc, options()), events, roots, policy, command)
+	}
+	lc.Stop()
+	if panicErr != nil {
+		fmt.Fprintf(os.Stderr, "arc %s failed: %v\n", command, panicErr)
+		return exitError
+	}
+
+	if *planPath != "" && command == controller.Plan {
+		if err := file_fs.WritePlan(*planPath, report.Plan); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	if *scriptPath != "" && command == controller.Plan {
+		if err := writeScript(*scriptPath, report.Plan); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printReport(os.Stdout, report)
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		return exitError
+	case command == controller.Apply:
+		return exitSynced
+	case len(report.Divergent) > 0 || len(report.Conflicts) > 0:
+		return exitDivergent
+	}
+	return exitSynced
+}
+
+func printReport(out io.Writer, report *controller.Report) {
+	fmt.Fprintf(out, "%s: %d roots, %d files, %d divergent, %d conflicts\n",
+		report.Command, len(report.Roots), report.Files, len(report.Divergent), len(report.Conflicts))
+	if len(report.Divergent) > 0 {
+		fmt.Fprintln(out, "Divergent:")
+		for _, file := range report.Divergent {
+			fmt.Fprintf(out, "  %-10s %s\n", file.Divergence, file.Path)
+		}
+	}
+	if len(report.Conflicts) > 0 {
+		fmt.Fprintln(out, "Conflicts:")
+		for _, conflict := range report.Conflicts {
+			fmt.Fprintf(out, "  %s: %d versions\n", conflict.Name, len(conflict.Versions))
+		}
+	}
+	if len(report.Commands) > 0 {
+		if report.Command != controller.Plan.String() {
+			fmt.Fprintln(out, "Applied:")
+		} else {
+			fmt.Fprintln(out, "Plan:")
+		}
+		for _, cmd := range report.Commands {
+			fmt.Fprintf(out, "  %s\n", cmd)
+		}
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(out, "Errors:")
+		for _, err := range report.Errors {
+			fmt.Fprintf(out, "  %s\n", err)
+		}
+	}
+}
+
+func writeScript(path string, plan m.Plan) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	err = file_fs.WriteScript(file, plan)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
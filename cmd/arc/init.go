@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"arc/files/file_fs"
+)
+
+// initArchives writes an identity into every root and returns the exit code.
+func initArchives(args []string) int {
+	flags := flag.NewFlagSet("arc init", flag.ContinueOnError)
+	label := flags.String("label", "", "human readable name of the archive (default the name of the root)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: arc init [-label name] root...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	roots, err := absRoots(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	result := exitSynced
+	for _, root := range roots {
+		name := *label
+		if name == "" {
+			name = filepath.Base(root.String())
+		}
+		identity, err := file_fs.InitArchive(root, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			result = exitError
+			continue
+		}
+		fmt.Printf("initialized %s as %q (%s)\n", root, identity.Label, identity.Id)
+	}
+	return result
+}
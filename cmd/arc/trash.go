@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"arc/files/file_fs"
+	m "arc/model"
+)
+
+// trash lists, restores or purges the trash of the roots and returns the exit code.
+func trash(args []string) int {
+	flags := flag.NewFlagSet("arc trash", flag.ContinueOnError)
+	olderThan := flags.Int("older-than", 0, "purge only files deleted more than the number of days ago")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: arc trash list root...")
+		fmt.Fprintln(flags.Output(), "       arc trash restore root path...")
+		fmt.Fprintln(flags.Output(), "       arc trash purge [-older-than days] root...")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	action := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	switch action {
+	case "list":
+		roots, err := absRoots(flags.Args())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		files, err := file_fs.ListTrash(roots)
+		printTrash(os.Stdout, files)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+
+	case "restore":
+		roots, err := absRoots(flags.Args()[:1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		files, err := file_fs.ListTrash(roots)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		result := exitSynced
+		for _, path := range flags.Args()[1:] {
+			if err := restore(files, m.Path(path).ParentName()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				result = exitError
+			}
+		}
+		return result
+
+	case "purge":
+		roots, err := absRoots(flags.Args())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		purged, err := file_fs.PurgeTrash(roots, time.Now().AddDate(0, 0, -*olderThan))
+		printTrash(os.Stdout, purged)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+
+	default:
+		flags.Usage()
+		return exitUsage
+	}
+	return exitSynced
+}
+
+// absRoots makes the paths absolute roots.
+func absRoots(paths []string) ([]m.Root, error) {
+	roots := make([]m.Root, len(paths))
+	for i, path := range paths {
+		path, err := file_fs.AbsPath(path)
+		if err != nil {
+			return nil, err
+		}
+		roots[i] = m.Root(path)
+	}
+	return roots, nil
+}
+
+// restore restores the most recently deleted file with the name.
+func restore(files []m.TrashedFile, name m.Name) error {
+	for _, file := range files {
+		if file.Name == name {
+			if err := file_fs.RestoreTrashed(file); err != nil {
+				return err
+			}
+			fmt.Printf("restored %s\n", file.Id)
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not in the trash", name)
+}
+
+func printTrash(out io.Writer, files []m.TrashedFile) {
+	for _, file := range files {
+		fmt.Fprintf(out, "%s  %12d  %s\n", file.Deleted.Local().Format(time.DateTime), file.Size, file.Id)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"arc/files/file_fs"
+)
+
+// verify drops the stale entries of the hash stores of the roots and returns the exit code.
+func verify(args []string) int {
+	flags := flag.NewFlagSet("arc verify", flag.ContinueOnError)
+	catalog := catalogFlag(flags)
+	rehash := flags.Bool("rehash", false, "read every file to find content changed under the same size and modification time")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: arc verify [-rehash] [-catalog root]... root...")
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), "Exit codes: 0 all stored hashes current, 1 stale hashes dropped, 2 errors, 3 usage")
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	roots, err := absRoots(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	result := exitSynced
+	for _, root := range roots {
+		stale, err := file_fs.VerifyHashStore(root, slices.Contains(catalog(), root), *rehash)
+		for _, hash := range stale {
+			fmt.Printf("%-8s %s\n", hash.Reason, hash.Id)
+			result = exitDivergent
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	return result
+}
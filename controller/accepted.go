@@ -0,0 +1,75 @@
+package controller
+
+import (
+	m "arc/model"
+	"path/filepath"
+	"slices"
+)
+
+// keepAll accepts the divergence of the selected files as intended,
+// so they stop showing as divergent until their copies change.
+func (c *controller) keepAll() {
+	hashes := map[m.Hash]struct{}{}
+	for _, file := range c.selectedFiles() {
+		if file.State == m.Divergent {
+			hashes[file.Hash] = struct{}{}
+		}
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	for hash := range hashes {
+		ids := []m.Id{}
+		for _, file := range c.byHash[hash] {
+			ids = append(ids, file.Id)
+		}
+		c.accepted[hash] = ids
+		c.analyzeDiscrepancy(hash)
+	}
+
+	files := []m.AcceptedFile{}
+	for hash, ids := range c.accepted {
+		if _, ok := c.byHash[hash]; !ok {
+			continue
+		}
+		for _, id := range ids {
+			files = append(files, m.AcceptedFile{Id: id, Hash: hash})
+		}
+	}
+	c.shared.fs.Send(m.StoreAccepted{Roots: c.roots, Files: files})
+	c.storeSnapshotIfSynced()
+}
+
+func (c *controller) isAccepted(hash m.Hash, files []*m.File) bool {
+	ids, ok := c.accepted[hash]
+	if !ok || len(ids) != len(files) {
+		return false
+	}
+	for _, file := range files {
+		if !slices.Contains(ids, file.Id) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedFiles returns the selected file or every file in the selected folder.
+func (c *controller) selectedFiles() []*m.File {
+	folder := c.archive.currentFolder()
+	if file, ok := folder.files[folder.selectedBase]; ok {
+		return []*m.File{file}
+	}
+	if folder.selectedBase == "" {
+		return nil
+	}
+	result := []*m.File{}
+	selectedPath := m.Path(filepath.Join(c.archive.currentPath.String(), folder.selectedBase.String()))
+	for path, folder := range c.archive.folders {
+		if isUnder(path, selectedPath) {
+			for _, file := range folder.files {
+				result = append(result, file)
+			}
+		}
+	}
+	return result
+}
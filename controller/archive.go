@@ -10,6 +10,7 @@ import (
 
 type archive struct {
 	root        m.Root
+	identity    m.Identity
 	idx         int
 	folders     map[m.Path]*folder
 	currentPath m.Path
@@ -59,18 +60,28 @@ func (a *archive) printTo(buf *strings.Builder) {
 }
 
 func (a *archive) renameEntry(file *m.File, newName m.Name) {
-	log.Printf("renameEntry: >>> from: %q, to: %q", file.Id, newName)
-	defer log.Printf("renameEntry: <<< from: %q, to: %q", file.Id, newName)
-	a.shared.fs.Send(m.RenameFile{
-		Hash: file.Hash,
-		From: file.Id,
-		To:   newName,
-	})
-
+	log.Printf("renameEntry: from: %q, to: %q", file.Id, newName)
 	delete(a.folders[file.Path].files, file.Base)
 	file.Name = newName
-	file.State = m.Resolved
-	a.folders[file.Path].files[file.Base] = file
+	a.getFolder(file.Path).files[file.Base] = file
+}
+
+func (a *archive) renameFolder(from, to m.Path) []*m.File {
+	log.Printf("renameFolder: from: %q, to: %q", from, to)
+	moved := []*m.File{}
+	for path, folder := range a.folders {
+		if isUnder(path, from) {
+			for _, file := range folder.files {
+				moved = append(moved, file)
+			}
+			delete(a.folders, path)
+		}
+	}
+	for _, file := range moved {
+		file.Path = m.Path(string(to) + string(file.Path[len(from):]))
+		a.getFolder(file.Path).files[file.Base] = file
+	}
+	return moved
 }
 
 func (a *archive) currentFolder() *folder {
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	m "arc/model"
+	v "arc/view"
+	"fmt"
+	"path/filepath"
+)
+
+type confirmation struct {
+	title   string
+	message string
+	action  func()
+}
+
+func (c *controller) confirmationView() *v.ConfirmDialog {
+	return &v.ConfirmDialog{Title: c.confirmation.title, Message: c.confirmation.message}
+}
+
+func (c *controller) handleConfirmationEvent(event any) bool {
+	switch event.(type) {
+	case m.Open, m.Enter:
+		action := c.confirmation.action
+		c.confirmation = nil
+		action()
+
+	case m.Cancel, m.Exit:
+		c.confirmation = nil
+
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *controller) deleteSelected() {
+	files := c.selectedFiles()
+	if len(files) == 0 {
+		return
+	}
+	commands := []m.FileCommand{}
+	for _, file := range files {
+		commands = append(commands, m.DeleteFile{Hash: file.Hash, Id: file.Id})
+	}
+	message := fmt.Sprintf("Delete %q?", files[0].Id)
+	if len(files) > 1 {
+		folder := c.archive.currentFolder()
+		path := filepath.Join(c.archive.root.String(), c.archive.currentPath.String(), folder.selectedBase.String())
+		message = fmt.Sprintf("Delete %d files in %q?", len(files), path)
+	}
+	c.confirmation = &confirmation{
+		title:   "Delete",
+		message: message,
+		action:  func() { c.apply(commands) },
+	}
+}
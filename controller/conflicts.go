@@ -0,0 +1,242 @@
+package controller
+
+import (
+	m "arc/model"
+	v "arc/view"
+	w "arc/widgets"
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+type screen int
+
+const (
+	folderScreen screen = iota
+	conflictsScreen
+	trashScreen
+)
+
+// conflict is a name with different content in different roots.
+type conflict struct {
+	name     m.Name
+	versions []*m.File
+}
+
+type conflictDialog struct {
+	name        m.Name
+	selectedIdx int
+}
+
+func (c *controller) updateConflicts() {
+	byName := map[m.Name][]*m.File{}
+	for _, archive := range c.archives {
+		for _, folder := range archive.folders {
+			for _, file := range folder.files {
+				if file.Hash != "" {
+					byName[file.Name] = append(byName[file.Name], file)
+				}
+			}
+		}
+	}
+	c.conflicts = map[m.Name]*conflict{}
+	for name, files := range byName {
+		if len(files) < 2 || c.skippedConflicts[name] {
+			continue
+		}
+		for _, file := range files[1:] {
+			if file.Hash != files[0].Hash {
+				slices.SortFunc(files, func(a, b *m.File) int {
+					return cmp.Compare(c.archives[a.Root].idx, c.archives[b.Root].idx)
+				})
+				c.conflicts[name] = &conflict{name: name, versions: files}
+				break
+			}
+		}
+	}
+}
+
+func (c *controller) sortedConflicts() []*conflict {
+	result := make([]*conflict, 0, len(c.conflicts))
+	for _, conflict := range c.conflicts {
+		result = append(result, conflict)
+	}
+	slices.SortFunc(result, func(a, b *conflict) int {
+		return cmp.Compare(a.name.String(), b.name.String())
+	})
+	return result
+}
+
+func (c *controller) rootWidget() w.Widget {
+	if c.confirmation != nil {
+		return c.confirmationView().RootWidget()
+	}
+	if c.review != nil {
+		return c.reviewView().RootWidget()
+	}
+	if c.conflictDialog != nil {
+		if dialog := c.conflictDialogView(); dialog != nil {
+			return dialog.RootWidget()
+		}
+		c.conflictDialog = nil
+	}
+	if c.screen == conflictsScreen {
+		return c.conflictsView().RootWidget()
+	}
+	if c.screen == trashScreen {
+		return c.trashView().RootWidget()
+	}
+	return c.view().RootWidget()
+}
+
+func (c *controller) conflictsView() *v.ConflictsView {
+	result := &v.ConflictsView{SelectedIdx: c.conflictIdx}
+	for _, conflict := range c.sortedConflicts() {
+		result.Conflicts = append(result.Conflicts, v.Conflict{Name: conflict.name, Versions: conflict.versions})
+	}
+	return result
+}
+
+func (c *controller) conflictDialogView() *v.ConflictDialog {
+	conflict, ok := c.conflicts[c.conflictDialog.name]
+	if !ok {
+		return nil
+	}
+	result := &v.ConflictDialog{
+		Conflict:    v.Conflict{Name: conflict.name, Versions: conflict.versions},
+		SelectedIdx: c.conflictDialog.selectedIdx,
+	}
+	for _, version := range conflict.distinctVersions() {
+		roots := ""
+		for _, file := range conflict.versions {
+			if file.Hash == version.Hash {
+				if roots != "" {
+					roots += ", "
+				}
+				roots += file.Root.String()
+			}
+		}
+		result.Options = append(result.Options, fmt.Sprintf("Keep the version from %s", roots))
+	}
+	result.Options = append(result.Options, "Keep all versions under suffixed names", "Skip")
+	return result
+}
+
+// distinctVersions returns the first file of every distinct hash, in root order.
+func (c *conflict) distinctVersions() []*m.File {
+	result := []*m.File{}
+	for _, file := range c.versions {
+		if !slices.ContainsFunc(result, func(other *m.File) bool { return other.Hash == file.Hash }) {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+func (c *controller) handleScreenEvent(event any) bool {
+	if c.confirmation != nil {
+		return c.handleConfirmationEvent(event)
+	}
+	if c.review != nil {
+		return c.handleReviewEvent(event)
+	}
+	if c.conflictDialog != nil {
+		return c.handleConflictDialogEvent(event)
+	}
+	if c.screen == conflictsScreen {
+		return c.handleConflictsEvent(event)
+	}
+	if c.screen == trashScreen {
+		return c.handleTrashEvent(event)
+	}
+	switch event.(type) {
+	case m.ShowConflicts:
+		c.screen = conflictsScreen
+		return true
+	case m.ShowTrash:
+		c.showTrash()
+		return true
+	}
+	return false
+}
+
+func (c *controller) handleConflictsEvent(event any) bool {
+	conflicts := c.sortedConflicts()
+	switch event := event.(type) {
+	case m.MoveSelection:
+		c.conflictIdx = clampIdx(c.conflictIdx+event.Lines, len(conflicts))
+
+	case m.Scroll:
+		c.conflictIdx = clampIdx(c.conflictIdx+event.Lines, len(conflicts))
+
+	case m.SelectFirst:
+		c.conflictIdx = 0
+
+	case m.SelectLast:
+		c.conflictIdx = clampIdx(len(conflicts)-1, len(conflicts))
+
+	case m.Open, m.Enter:
+		if c.conflictIdx < len(conflicts) {
+			c.conflictDialog = &conflictDialog{name: conflicts[c.conflictIdx].name}
+		}
+
+	case m.Cancel, m.Exit, m.ShowConflicts:
+		c.screen = folderScreen
+
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *controller) handleConflictDialogEvent(event any) bool {
+	conflict, ok := c.conflicts[c.conflictDialog.name]
+	if !ok {
+		c.conflictDialog = nil
+		return false
+	}
+	versions := conflict.distinctVersions()
+	options := len(versions) + 2
+
+	switch event := event.(type) {
+	case m.MoveSelection:
+		c.conflictDialog.selectedIdx = clampIdx(c.conflictDialog.selectedIdx+event.Lines, options)
+
+	case m.Scroll:
+		c.conflictDialog.selectedIdx = clampIdx(c.conflictDialog.selectedIdx+event.Lines, options)
+
+	case m.Open, m.Enter:
+		idx := c.conflictDialog.selectedIdx
+		switch {
+		case idx < len(versions):
+			p := c.newPlanner()
+			p.keepVersion(versions[idx].Id)
+			c.apply(p.plan())
+		case idx == len(versions):
+			p := c.newPlanner()
+			p.keepAll(conflict.name)
+			c.apply(p.plan())
+		default:
+			c.skippedConflicts[conflict.name] = true
+			c.updateConflicts()
+		}
+		c.conflictDialog = nil
+
+	case m.Cancel, m.Exit:
+		c.conflictDialog = nil
+
+	default:
+		return false
+	}
+	return true
+}
+
+func clampIdx(idx, length int) int {
+	if idx >= length {
+		idx = length - 1
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	return idx
+}
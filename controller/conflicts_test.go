@@ -0,0 +1,41 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+)
+
+func TestConflicts(t *testing.T) {
+	metas := map[m.Root]map[string]m.Hash{
+		"origin": {"a/b.txt": "bbbb", "c.txt": "cccc"},
+		"copy 1": {"a/b.txt": "BBBB", "c.txt": "cccc"},
+		"copy 2": {"a/b.txt": "bbbb"},
+	}
+	c := newTestController(metas)
+	if len(c.conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %d", len(c.conflicts))
+	}
+	conflict := c.conflicts[testName("a/b.txt")]
+	if conflict == nil || len(conflict.versions) != 3 || len(conflict.distinctVersions()) != 2 {
+		t.Fatalf("unexpected conflict: %#v", conflict)
+	}
+
+	p := c.newPlanner()
+	p.keepVersion(testId("copy 1", "a/b.txt"))
+	expected := "delete origin/a/b.txt\ndelete copy 2/a/b.txt\ncopy copy 1/a/b.txt -> origin/a/b.txt, copy 2/a/b.txt"
+	if plan := planString(p.plan()); plan != expected {
+		t.Errorf("keep version: expected:\n%s\ngot:\n%s", expected, plan)
+	}
+
+	p = c.newPlanner()
+	p.keepAll(testName("a/b.txt"))
+	expected = "rename copy 1/a/b.txt -> a/b`1.txt\ncopy origin/a/b.txt -> copy 1/a/b.txt\ncopy copy 1/a/b`1.txt -> origin/a/b`1.txt, copy 2/a/b`1.txt"
+	if plan := planString(p.plan()); plan != expected {
+		t.Errorf("keep all: expected:\n%s\ngot:\n%s", expected, plan)
+	}
+
+	c.execute(p.plan())
+	if len(c.conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %d", len(c.conflicts))
+	}
+}
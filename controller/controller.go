@@ -14,6 +14,33 @@ type controller struct {
 	byHash   map[m.Hash][]*m.File
 	archive  *archive
 	hashed   int
+	policy   m.Policy
+	policies map[m.Path]m.Policy
+	snapshot *snapshot
+	accepted map[m.Hash][]m.Id
+	analyzed bool
+
+	hashRequested map[m.Id]bool
+	partials      map[m.Id]m.Hash
+	hashing       int
+	afterHashing  func()
+
+	interrupted []m.InterruptedCopy
+	journal     []m.JournalEntry
+	session     string
+	undoing     map[string]*undoing
+	pending     int
+
+	screen           screen
+	conflicts        map[m.Name]*conflict
+	skippedConflicts map[m.Name]bool
+	conflictIdx      int
+	conflictDialog   *conflictDialog
+	trash            []m.TrashedFile
+	trashIdx         int
+	confirmation     *confirmation
+	dryRun           bool
+	review           *planReview
 
 	*shared
 
@@ -38,21 +65,49 @@ type shared struct {
 	fs  m.FS
 }
 
-func Run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root) (err any, stack []byte) {
+func Run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root, policy m.Policy, dryRun bool) (err any, stack []byte) {
 	defer func() {
 		err = recover()
 		stack = debug.Stack()
 	}()
-	run(fs, renderer, events, roots)
+	run(fs, renderer, events, roots, policy, dryRun)
 	return nil, nil
 }
 
-func run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root) {
+func run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root, policy m.Policy, dryRun bool) {
+	c := newController(fs, roots, policy)
+	c.dryRun = dryRun
+
+	for !c.quit {
+		events, _ := events.Pull()
+		for _, event := range events {
+			c.handleEvent(event)
+		}
+
+		c.frames++
+		screen := w.NewScreen(c.screenSize)
+		rootWidget := c.rootWidget()
+		rootWidget.Render(screen, w.Position{X: 0, Y: 0}, c.screenSize)
+		renderer.Push(screen)
+	}
+}
+
+func newController(fs m.FS, roots []m.Root, policy m.Policy) *controller {
 	c := &controller{
 		roots:    roots,
 		archives: map[m.Root]*archive{},
 		byHash:   map[m.Hash][]*m.File{},
-		shared:   &shared{},
+		policy:   policy,
+		policies: map[m.Path]m.Policy{},
+		accepted: map[m.Hash][]m.Id{},
+
+		hashRequested: map[m.Id]bool{},
+		partials:      map[m.Id]m.Hash{},
+		undoing:       map[string]*undoing{},
+		shared:        &shared{},
+
+		conflicts:        map[m.Name]*conflict{},
+		skippedConflicts: map[m.Name]bool{},
 	}
 	c.shared.fs = fs
 
@@ -60,20 +115,8 @@ func run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m
 		c.archives[root] = newArchive(root, idx, c.shared)
 		c.shared.fs.Scan(root)
 	}
+	c.shared.fs.LoadSnapshot(roots)
 
 	c.archive = c.archives[roots[0]]
-
-	for !c.quit {
-		events, _ := events.Pull()
-		for _, event := range events {
-			c.handleEvent(event)
-		}
-
-		c.frames++
-		screen := w.NewScreen(c.screenSize)
-		view := c.view()
-		rootWidget := view.RootWidget()
-		rootWidget.Render(screen, w.Position{X: 0, Y: 0}, c.screenSize)
-		renderer.Push(screen)
-	}
+	return c
 }
@@ -3,6 +3,8 @@ package controller
 import (
 	m "arc/model"
 	v "arc/view"
+	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -11,8 +13,12 @@ func (c *controller) view() *v.View {
 	currentFolder := archive.currentFolder()
 	view := &v.View{
 		Archive:   archive.root,
+		Label:     archive.identity.Label,
 		Path:      archive.currentPath,
 		OffsetIdx: currentFolder.offsetIdx,
+		Policy:    c.policyFor(archive.currentPath),
+		Conflicts: len(c.conflicts),
+		DryRun:    c.dryRun,
 	}
 
 	subFolders := map[m.Base]v.Entry{}
@@ -88,6 +94,16 @@ func (c *controller) view() *v.View {
 	}
 	view.SelectedBase = currentFolder.selectedBase
 
+	if file, ok := currentFolder.files[currentFolder.selectedBase]; ok {
+		copies := c.copies(file)
+		view.Copies = len(copies)
+		view.CopyIdx = slices.Index(copies, file)
+		if file.State == m.Divergent {
+			truth, reason := c.pick(file)
+			view.Reason = fmt.Sprintf("keeps %s: %s", truth.Id, reason)
+		}
+	}
+
 	return view
 }
 
@@ -2,19 +2,20 @@ package controller
 
 import (
 	m "arc/model"
-	v "arc/view"
 	w "arc/widgets"
+	"cmp"
 	"fmt"
 	"log"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
 func (c *controller) handleEvent(event any) {
 	// log.Printf("### event: %T: %s", event, event)
-	if event == nil {
+	if event == nil || c.handleScreenEvent(event) {
 		return
 	}
 	switch event := event.(type) {
@@ -28,6 +29,14 @@ func (c *controller) handleEvent(event any) {
 	case m.ArchiveScanned:
 		c.archives[event.Root].scanned = true
 
+	case m.ArchiveIdentified:
+		for _, archive := range c.archives {
+			if archive.identity.Id == event.Id {
+				c.handleEvent(m.Error{Error: fmt.Errorf("%s and %s are the same archive %q", archive.root, event.Root, event.Label)})
+			}
+		}
+		c.archives[event.Root].identity = event.Identity
+
 	case m.FileHashed:
 		file := c.archives[event.Root].getFolder(event.Path).files[event.Base]
 		file.Hash = event.Hash
@@ -36,24 +45,84 @@ func (c *controller) handleEvent(event any) {
 
 	case m.ArchiveHashed:
 		c.archives[event.Root].state = ready
-		for _, archive := range c.archives {
-			if archive.state != ready {
-				return
-			}
-		}
-		c.analyzeDiscrepancies()
+		c.analyzeIfReady()
+
+	case m.SnapshotLoaded:
+		c.snapshotLoaded(event)
 
 	case m.FileDeleted:
-		// No Action Needed
+		c.pending--
+		c.storeSnapshotIfSynced()
+
+	case m.DeleteFailed:
+		c.pending--
+		c.deleteFailed(event)
 
 	case m.FileRenamed:
-		// No Action Needed
+		c.pending--
+		if file := c.file(m.Id{Root: event.From.Root, Name: event.To}); file != nil && file.State == m.Pending {
+			file.State = m.Resolved
+		}
+		c.storeSnapshotIfSynced()
+
+	case m.FolderRenamed:
+		c.pending--
+		for path, folder := range c.archives[event.Root].folders {
+			if isUnder(path, event.To) {
+				for _, file := range folder.files {
+					if file.State == m.Pending {
+						file.State = m.Resolved
+					}
+				}
+			}
+		}
+		c.storeSnapshotIfSynced()
 
 	case m.FileCopied:
-		c.archives[event.From.Root].folders[event.From.Path].files[event.From.Base].State = m.Resolved
+		c.pending--
+		if file := c.file(event.From); file != nil {
+			if file.State == m.Pending {
+				file.State = m.Resolved
+			}
+			c.totalCopiedSize += file.Size
+			c.fileCopiedSize = 0
+		}
 		for _, to := range event.To {
-			c.archives[to.Root].folders[to.Path].files[to.Base].State = m.Resolved
+			if file := c.file(to); file != nil {
+				file.State = m.Resolved
+			}
+		}
+		c.storeSnapshotIfSynced()
+
+	case m.HashMismatch:
+		if file := c.file(event.Id); file != nil {
+			c.removeFile(file)
+			c.analyzeDiscrepancy(file.Hash)
+			c.updateConflicts()
 		}
+		c.errors = append(c.errors, m.Error{Id: event.Id, Error: fmt.Errorf("copy has hash %s instead of %s", event.Actual, event.Expected)})
+
+	case m.PartialHashed:
+		c.partials[event.Id] = event.Hash
+
+	case m.FilesHashed:
+		c.filesHashed(event)
+
+	case m.TrashListed:
+		c.trashListed(event)
+
+	case m.FileRestored:
+		c.fileRestored(event)
+		c.reversed(undoKey(m.RestoreFile(event)))
+
+	case m.Journaled:
+		c.journaled(event)
+
+	case m.Undo:
+		c.undo(event.Session)
+
+	case m.ShowConflicts, m.ShowTrash:
+		// Screens are switched from the folder view only
 
 	case m.HashingProgress:
 		c.handleHashingProgress(event)
@@ -109,8 +178,11 @@ func (c *controller) handleEvent(event any) {
 		folder.moveOffset(c.archive.fileTreeLines, c.archive.fileTreeLines)
 		folder.moveSelection(c.archive.fileTreeLines)
 
+	case m.CyclePolicy:
+		c.cyclePolicy()
+
 	case m.Tab:
-		c.tab()
+		c.tab(event.Backward)
 		c.archive.currentFolder().makeSelectedVisible(c.archive.fileTreeLines)
 
 	case m.ResolveOne:
@@ -120,16 +192,29 @@ func (c *controller) handleEvent(event any) {
 		c.resolveAll()
 
 	case m.KeepAll:
-		// TODO: Implement, maybe?
+		c.keepAll()
 
 	case m.Delete:
-		// folder := c.currentFolder()
-		// c.deleteFile(folder.selectedEntry)
+		c.deleteSelected()
+
+	case m.ToggleDryRun:
+		c.dryRun = !c.dryRun
 
 	case m.Error:
 		log.Printf("### Error: %s", event)
 		c.errors = append(c.errors, event)
 
+	case m.Cancel:
+		// Nothing to cancel
+
+	case m.ToggleOperation, m.SavePlan:
+		// Only the plan review has operations
+
+	case m.PlanStored:
+		if c.review != nil {
+			c.review.savedPath = event.Path
+		}
+
 	case m.Quit:
 		c.quit = true
 
@@ -137,7 +222,7 @@ func (c *controller) handleEvent(event any) {
 		log.Println(c.String())
 
 	case m.DebugPrintRootWidget:
-		log.Println(c.view().RootWidget())
+		log.Println(c.rootWidget())
 
 	default:
 		log.Panicf("### unhandled event: %#v", event)
@@ -231,35 +316,46 @@ func (a *archive) mouseTarget(cmd any) {
 	}
 }
 
-func (c *controller) tab() {
-	panic("IMPLEMENT c.tab()")
-	// selected := c.archive.currentFolder().selected()
-	// file, ok := selected.(*m.File)
-	// if !ok {
-	// 	return
-	// }
-
-	// sameHash := c.byHash[file.Hash]
+func (c *controller) tab(backward bool) {
+	folder := c.archive.currentFolder()
+	file, ok := folder.files[folder.selectedBase]
+	if !ok {
+		return
+	}
+	copies := c.copies(file)
+	idx := slices.Index(copies, file)
+	if backward {
+		idx += len(copies) - 1
+	} else {
+		idx++
+	}
+	next := copies[idx%len(copies)]
 
-	// slices.SortFunc(sameHash, func(a, b *m.File) int {
-	// 	result := cmp.Compare(c.archives[a.Root].idx, c.archives[b.Root].idx)
-	// 	if result != 0 {
-	// 		return result
-	// 	}
-	// 	result = cmp.Compare(a.Path, b.Path)
-	// 	if result != 0 {
-	// 		return result
-	// 	}
-	// 	return cmp.Compare(a.Base, b.Base)
-	// })
+	c.archive = c.archives[next.Root]
+	c.archive.currentPath = next.Path
+	c.archive.currentFolder().selectedBase = next.Base
+	// Building the view resolves the selected index for makeSelectedVisible.
+	c.view()
+}
 
-	// idx := slices.Index(sameHash, file)
-	// var newSelected m.Entry = sameHash[(idx+1)%len(sameHash)]
-	// c.archive = c.archives[newSelected.Meta().Root]
-	// c.archive.currentPath = newSelected.Meta().Path
-	// folder := c.archive.currentFolder()
-	// folder.selectedIdx = slices.Index(folder.entries, newSelected)
-	// folder.makeSelectedVisible(c.archive.fileTreeLines)
+// copies returns every file with the same hash ordered by root, path and name.
+func (c *controller) copies(file *m.File) []*m.File {
+	copies := slices.Clone(c.byHash[file.Hash])
+	if file.Hash == "" || !slices.Contains(copies, file) {
+		return []*m.File{file}
+	}
+	slices.SortFunc(copies, func(a, b *m.File) int {
+		result := cmp.Compare(c.archives[a.Root].idx, c.archives[b.Root].idx)
+		if result != 0 {
+			return result
+		}
+		result = cmp.Compare(a.Path, b.Path)
+		if result != 0 {
+			return result
+		}
+		return cmp.Compare(a.Base, b.Base)
+	})
+	return copies
 }
 
 func (c *controller) String() string {
@@ -320,42 +416,177 @@ func (c *controller) analyzeDiscrepancy(hash m.Hash) {
 		}
 	}
 
+	if divergent && c.isAccepted(hash, files) {
+		divergent = false
+	}
+
 	if divergent {
 		c.setStates(files, m.Divergent)
 		c.setCounts(files, m.Divergent)
+		for _, file := range files {
+			file.Divergence = c.classify(file, files)
+		}
+	} else {
+		for _, file := range files {
+			if file.State == m.Divergent {
+				file.State = m.Resolved
+			}
+			file.Divergence = m.NoDivergence
+		}
+		c.setCounts(files, m.Resolved)
 	}
 }
 
+// classify tells what kind of divergence a file has compared to the other
+// files with the same hash, the most severe kind first.
+func (c *controller) classify(file *m.File, sameHash []*m.File) m.Divergence {
+	if file.Counts[c.archives[file.Root].idx] > 1 {
+		return m.Duplicated
+	}
+	for _, root := range c.roots {
+		if other := c.file(m.Id{Root: root, Name: file.Name}); other != nil && other.Hash != file.Hash {
+			return m.Changed
+		}
+	}
+	result := m.NoDivergence
+	for _, root := range c.roots {
+		if root == file.Root {
+			continue
+		}
+		if other := c.file(m.Id{Root: root, Name: file.Name}); other != nil {
+			continue
+		}
+		found := false
+		for _, other := range sameHash {
+			if other.Root != root {
+				continue
+			}
+			found = true
+			if other.Path != file.Path {
+				result = m.Moved
+			} else if result != m.Moved {
+				result = m.Renamed
+			}
+		}
+		if !found && result == m.NoDivergence {
+			result = m.Missing
+		}
+	}
+	if result == m.NoDivergence {
+		result = m.Missing
+	}
+	return result
+}
+
 func (c *controller) resolveSelected() {
-	panic("IMPLEMENT c.resolveSelected()")
-	// c.resolveFile(c.archive.currentFolder().selectedEntry)
+	if c.hashUnique(c.resolveSelected) {
+		return
+	}
+	folder := c.archive.currentFolder()
+	p := c.newPlanner()
+	if file, ok := folder.files[folder.selectedBase]; ok {
+		p.resolve(file.Id)
+	} else if folder.selectedBase != "" {
+		p.resolveFolder(c.archive.root, m.Path(filepath.Join(c.archive.currentPath.String(), folder.selectedBase.String())))
+	}
+	c.apply(p.plan())
 }
 
 func (c *controller) resolveAll() {
-	c.resolveFolder(c.archive.currentPath)
+	if c.hashUnique(c.resolveAll) {
+		return
+	}
+	p := c.newPlanner()
+	p.resolveFolder(c.archive.root, c.archive.currentPath)
+	c.apply(p.plan())
+}
+
+func (c *controller) newPlanner() *planner {
+	return newPlanner(c.roots, c.archives, c.byHash, c.policyFor, c.snapshot)
+}
+
+func (c *controller) execute(commands []m.FileCommand) {
+	hashes := map[m.Hash]struct{}{}
+	for _, cmd := range commands {
+		log.Printf("execute: %v", cmd)
+		switch cmd := cmd.(type) {
+		case m.RenameFile:
+			file := c.file(cmd.From)
+			c.archives[cmd.From.Root].renameEntry(file, cmd.To)
+			file.State = m.Pending
+			hashes[file.Hash] = struct{}{}
+
+		case m.RenameFolder:
+			for _, file := range c.archives[cmd.Root].renameFolder(cmd.From, cmd.To) {
+				file.State = m.Pending
+				hashes[file.Hash] = struct{}{}
+			}
+
+		case m.DeleteFile:
+			file := c.file(cmd.Id)
+			c.removeFile(file)
+			hashes[file.Hash] = struct{}{}
+
+		case m.CopyFile:
+			source := c.file(cmd.From)
+			source.State = m.Pending
+			for _, to := range cmd.To {
+				file := &m.File{
+					Meta:  m.Meta{Id: to, Size: source.Size, ModTime: source.ModTime},
+					Hash:  source.Hash,
+					State: m.Pending,
+				}
+				c.archives[to.Root].getFolder(to.Path).files[to.Base] = file
+				c.byHash[file.Hash] = append(c.byHash[file.Hash], file)
+			}
+			c.copySize += source.Size
+			hashes[source.Hash] = struct{}{}
+		}
+		c.pending++
+		c.shared.fs.Send(cmd)
+	}
+	for hash := range hashes {
+		c.analyzeDiscrepancy(hash)
+	}
+	c.updateConflicts()
 }
 
-func (c *controller) resolveFile(entry v.Entry) {
-	switch entry.Kind {
-	case v.Regular:
-		c.resolveRegularFile(entry.File)
-	case v.Folder:
-		c.resolveFolder(entry.Path)
+func (c *controller) file(id m.Id) *m.File {
+	archive, ok := c.archives[id.Root]
+	if !ok {
+		return nil
 	}
+	folder, ok := archive.folders[id.Path]
+	if !ok {
+		return nil
+	}
+	return folder.files[id.Base]
 }
 
-func (c *controller) resolveFolder(path m.Path) {
-	panic("IMPLEMENT c.resolveFolder()")
-	// for _, entry := range c.archive.folders[path].entries {
-	// 	switch entry := entry.(type) {
-	// 	case *m.File:
-	// 		if entry.State == m.Divergent && entry.Counts[c.archive.idx] == 1 {
-	// 			c.resolveFile(entry)
-	// 		}
-	// 	case *m.Folder:
-	// 		c.resolveFolder(m.Path(entry.Name.String()))
-	// 	}
-	// }
+// deleteFailed brings back the file removed from the catalog when the delete was sent.
+func (c *controller) deleteFailed(event m.DeleteFailed) {
+	if !event.Kept || c.file(event.Id) != nil {
+		return
+	}
+	file := m.NewFile(event.Meta, m.Hashed)
+	file.Hash = event.Hash
+	c.archives[event.Root].getFolder(event.Path).files[event.Base] = file
+	c.byHash[file.Hash] = append(c.byHash[file.Hash], file)
+	c.analyzeDiscrepancy(file.Hash)
+	c.updateConflicts()
+}
+
+func (c *controller) removeFile(file *m.File) {
+	archive := c.archives[file.Root]
+	folder := archive.folders[file.Path]
+	delete(folder.files, file.Base)
+	if len(folder.files) == 0 && file.Path != archive.currentPath {
+		delete(archive.folders, file.Path)
+	}
+	files := c.byHash[file.Hash]
+	if idx := slices.Index(files, file); idx >= 0 {
+		c.byHash[file.Hash] = slices.Delete(files, idx, idx+1)
+	}
 }
 
 func (c *controller) setStates(files []*m.File, state m.State) {
@@ -387,156 +618,6 @@ func (c *controller) setCounts(files []*m.File, state m.State) {
 	}
 }
 
-func (c *controller) resolveRegularFile(file *m.File) {
-	panic("IMPLEMENT: c.resolveRegularFile()")
-	// c.clearName(file)
-
-	// for _, archive := range c.archives {
-	// 	if folder, ok := archive.folders[file.Path]; ok {
-	// 		entry := folder.entry(file.Base)
-	// 		if archiveFile, ok := entry.(*m.File); ok && archiveFile.Hash != file.Hash {
-
-	// 		}
-	// 	}
-	// }
-
-	// c.cleanPath(file)
-
-	// log.Printf("resolveFile: file: %s", file)
-	// for _, archive := range c.archives {
-	// 	log.Printf("resolveFile: archive:1: %q", archive.root)
-	// 	archive.clearPath(file.Path)
-
-	// 	var sameName m.Entry
-	// 	folder := archive.folders[file.Path]
-	// 	if folder == nil {
-	// 		continue
-	// 	}
-
-	// 	for _, entry := range folder.entries {
-	// 		if entry.Name == file.Name {
-	// 			sameName = entry
-	// 			break
-	// 		}
-	// 	}
-	// 	if sameName == nil {
-	// 		continue
-	// 	}
-
-	// 	if sameName.Hash != file.Hash {
-	// 		log.Printf("resolveFile: found deverdent: id: %q, hash: %q", sameName.Id, sameName.Hash)
-	// 		newBase := folder.uniqueName(file.Base)
-	// 		newName := m.Name{Path: file.Path, Base: newBase}
-	// 		archive.renameEntry(sameName, newName)
-	// 		sameName.State = m.Pending
-	// 		file.State = m.Pending
-
-	// 		log.Printf("resolveFile: handled deverdent: id: %q, hash: %q", sameName.Id, sameName.Hash)
-	// 	}
-	// }
-
-	// copyRoots := []m.Id{}
-	// for _, archive := range c.archives {
-	// 	log.Printf("resolveFile: archive:2: %q", archive.root)
-	// 	sameHash := []*m.File{}
-	// 	for _, entry := range c.byHash[file.Hash] {
-	// 		if entry.Root == archive.root {
-	// 			sameHash = append(sameHash, entry)
-	// 			log.Printf("resolveFile: archive:2: sameHash: %q", entry.Id)
-	// 		}
-	// 	}
-	// 	if len(sameHash) == 0 {
-	// 		log.Printf("resolveFile: archive:2: no entries")
-	// 		copyRoots = append(copyRoots, m.Id{Root: archive.root, Name: file.Name})
-	// 		newFile := &m.Entry{
-	// 			Meta: m.Meta{
-	// 				Id:      m.Id{Root: archive.root, Name: file.Name},
-	// 				Size:    file.Size,
-	// 				ModTime: file.ModTime,
-	// 			},
-	// 			Hash:  file.Hash,
-	// 			Kind:  m.FileRegular,
-	// 			State: m.Pending,
-	// 		}
-	// 		file.State = m.Pending
-	// 		log.Printf("resolveFile: archive:2: add entry: %q", newFile.Id)
-	// 		archive.addFile(newFile)
-	// 		c.byHash[newFile.Hash] = append(c.byHash[newFile.Hash], newFile)
-	// 		continue
-	// 	}
-	// 	var keep m.Entry
-	// 	for _, entry := range sameHash {
-	// 		if entry.Name == file.Name {
-	// 			keep = entry
-	// 		}
-	// 	}
-	// 	if keep == nil {
-	// 		keep = sameHash[0]
-	// 	}
-	// 	log.Printf("resolveFile: archive:2: keep: %q", keep.Id)
-	// 	if keep.Name != file.Name {
-	// 		archive.renameEntry(keep, file.Name)
-	// 		keep.State = m.Pending
-	// 		file.State = m.Pending
-	// 	}
-
-	// 	for _, other := range sameHash {
-	// 		if other == keep {
-	// 			continue
-	// 		}
-	// 		log.Printf("resolveFile: archive:2: other: %q", other.Id)
-
-	// 		c.shared.fs.Send(m.DeleteFile{
-	// 			Hash: keep.Hash,
-	// 			Id:   other.Id,
-	// 		})
-	// 		file.State = m.Pending
-	// 		archive.folders[other.Path].deleteEntry(other.Base)
-	// 		files := c.byHash[keep.Hash]
-	// 		for i, file := range files {
-	// 			if file.Id == other.Id {
-	// 				files[i] = files[len(files)-1]
-	// 				c.byHash[keep.Hash] = files[:len(files)-1]
-	// 				break
-	// 			}
-	// 		}
-	// 	}
-	// }
-	// if len(copyRoots) > 0 {
-	// 	c.shared.fs.Send(m.CopyFile{
-	// 		Hash: file.Hash,
-	// 		From: file.Id,
-	// 		To:   copyRoots,
-	// 	})
-	// 	file.State = m.Pending
-	// }
-}
-
-func (c *controller) clearName(file *m.File) {
-	if c.nameCollidesWithPath(file.Name) {
-		var newName m.Name
-		for i := 1; ; i++ {
-			newName = newSuffix(file.Name, i)
-			if !c.nameCollidesWithPath(newName) {
-				break
-			}
-		}
-		if newName != file.Name {
-			c.archives[file.Root].renameEntry(file, newName)
-		}
-	}
-}
-
-func (c *controller) nameCollidesWithPath(name m.Name) bool {
-	path := name.ChildPath()
-	for _, archive := range c.archives {
-		if _, ok := archive.folders[path]; ok {
-			return true
-		}
-	}
-	return false
-}
-
 func newSuffix(name m.Name, idx int) m.Name {
 	parts := strings.Split(name.Base.String(), ".")
 
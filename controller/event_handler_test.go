@@ -0,0 +1,142 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"missing": "mmmm", "renamed": "rrrr", "a/moved": "vvvv", "changed": "cccc", "same": "ssss"},
+		"copy 1": {"missing": "mmmm", "renamed-1": "rrrr", "b/moved": "vvvv", "changed": "CCCC", "same": "ssss", "dup": "dddd"},
+		"copy 2": {"renamed": "rrrr", "a/moved": "vvvv", "changed": "cccc", "same": "ssss", "dup": "dddd", "a/dup": "dddd"},
+	})
+	tests := []struct {
+		id         m.Id
+		divergence m.Divergence
+	}{
+		{testId("origin", "missing"), m.Missing},
+		{testId("copy 1", "renamed-1"), m.Renamed},
+		{testId("origin", "renamed"), m.Renamed},
+		{testId("copy 1", "b/moved"), m.Moved},
+		{testId("copy 1", "changed"), m.Changed},
+		{testId("origin", "changed"), m.Changed},
+		{testId("copy 2", "a/dup"), m.Duplicated},
+		{testId("copy 1", "dup"), m.Missing},
+		{testId("origin", "same"), m.NoDivergence},
+	}
+	for _, test := range tests {
+		if file := c.file(test.id); file.Divergence != test.divergence {
+			t.Errorf("%s: expected %q, got %q", test.id, test.divergence, file.Divergence)
+		}
+	}
+}
+
+func TestTab(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"a/x.txt": "xxxx"},
+		"copy 1": {"b/y.txt": "xxxx", "z.txt": "xxxx"},
+		"copy 2": {"x.txt": "xxxx"},
+	})
+	c.archive.currentPath = "a"
+	c.archive.currentFolder().selectedBase = "x.txt"
+
+	expected := []m.Id{testId("copy 1", "z.txt"), testId("copy 1", "b/y.txt"), testId("copy 2", "x.txt"), testId("origin", "a/x.txt")}
+	for _, id := range expected {
+		c.handleEvent(m.Tab{})
+		if c.archive.root != id.Root || c.archive.currentPath != id.Path || c.archive.currentFolder().selectedBase != id.Base {
+			t.Errorf("expected %q, got %q/%q/%q", id, c.archive.root, c.archive.currentPath, c.archive.currentFolder().selectedBase)
+		}
+	}
+
+	c.handleEvent(m.Tab{Backward: true})
+	if view := c.view(); c.archive.root != "copy 2" || view.CopyIdx != 3 || view.Copies != 4 {
+		t.Errorf("expected copy 4 of 4 in %q, got copy %d of %d in %q", "copy 2", view.CopyIdx+1, view.Copies, c.archive.root)
+	}
+}
+
+func TestKeepAll(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx"},
+		"copy 1": {"y.txt": "xxxx"},
+		"copy 2": {"x.txt": "xxxx"},
+	})
+	c.archive.currentFolder().selectedBase = "x.txt"
+	c.handleEvent(m.KeepAll{})
+
+	if file := c.file(testId("copy 1", "y.txt")); file.State != m.Resolved {
+		t.Errorf("expected accepted file to be resolved, got %s", file.State)
+	}
+	if cmd, ok := c.shared.fs.(*testFs).commands[0].(m.StoreAccepted); !ok || len(cmd.Files) != 3 {
+		t.Errorf("expected accepted files to be stored, got %#v", c.shared.fs.(*testFs).commands)
+	}
+
+	c.execute([]m.FileCommand{m.DeleteFile{Hash: "xxxx", Id: testId("copy 2", "x.txt")}})
+	if file := c.file(testId("copy 1", "y.txt")); file.State != m.Divergent {
+		t.Errorf("expected file to diverge again, got %s", file.State)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"a/x.txt": "xxxx", "a/b/y.txt": "yyyy", "z.txt": "zzzz"},
+		"copy 1": {"a/x.txt": "xxxx"},
+	})
+	c.archive.currentFolder().selectedBase = "a"
+	c.handleEvent(m.Delete{})
+	if c.confirmation == nil || len(c.shared.fs.(*testFs).commands) != 0 {
+		t.Fatalf("expected confirmation before deleting")
+	}
+
+	c.handleEvent(m.Cancel{})
+	if c.confirmation != nil || len(c.shared.fs.(*testFs).commands) != 0 {
+		t.Fatalf("expected cancel to keep the files")
+	}
+
+	c.handleEvent(m.Delete{})
+	c.handleEvent(m.Enter{})
+	if commands := c.shared.fs.(*testFs).commands; len(commands) != 2 {
+		t.Errorf("expected 2 delete commands, got %#v", commands)
+	}
+	if file := c.file(testId("copy 1", "a/x.txt")); file.State != m.Divergent || file.Divergence != m.Missing {
+		t.Errorf("expected remaining copy to be missing, got %s/%s", file.State, file.Divergence)
+	}
+}
+
+func TestHashMismatch(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx"},
+		"copy 1": {"x.txt": "xxxx"},
+	})
+	c.execute([]m.FileCommand{m.CopyFile{Hash: "xxxx", From: testId("origin", "x.txt"), To: []m.Id{testId("copy 2", "x.txt")}}})
+	c.handleEvent(m.HashMismatch{Id: testId("copy 2", "x.txt"), Expected: "xxxx", Actual: "yyyy"})
+	c.handleEvent(m.FileCopied{Hash: "xxxx", From: testId("origin", "x.txt"), To: []m.Id{testId("copy 2", "x.txt")}})
+
+	if file := c.file(testId("copy 2", "x.txt")); file != nil {
+		t.Errorf("expected the bad copy to be dropped, got %v", file)
+	}
+	if file := c.file(testId("origin", "x.txt")); file.State != m.Divergent || len(c.errors) != 1 {
+		t.Errorf("expected the source to diverge again with an error, got %s, %v", file.State, c.errors)
+	}
+}
+
+func TestDeleteFailed(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"a.txt": "aaaa"},
+		"copy 1": {"a.txt": "aaaa"},
+	})
+	id := testId("copy 1", "a.txt")
+	file := c.file(id)
+	c.execute([]m.FileCommand{m.DeleteFile{Id: id, Hash: "aaaa"}})
+	if c.file(id) != nil {
+		t.Fatal("expected the file to be removed while the delete runs")
+	}
+
+	c.handleEvent(m.DeleteFailed{Meta: file.Meta, Hash: "aaaa", Kept: true})
+	if kept := c.file(id); kept == nil || kept.Hash != "aaaa" || kept.State != m.Divergent {
+		t.Errorf("expected the kept file back, got %v", kept)
+	}
+	if c.pending != 0 {
+		t.Errorf("expected no pending commands, got %d", c.pending)
+	}
+}
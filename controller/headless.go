@@ -0,0 +1,227 @@
+package controller
+
+import (
+	m "arc/model"
+	"arc/stream"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Command int
+
+const (
+	Status Command = iota
+	Plan
+	Sync
+	Apply
+)
+
+func (c Command) String() string {
+	switch c {
+	case Status:
+		return "status"
+	case Plan:
+		return "plan"
+	case Sync:
+		return "sync"
+	case Apply:
+		return "apply"
+	}
+	return "Illegal Command"
+}
+
+// Report is the outcome of a run without the user interface.
+type Report struct {
+	Command   string           `json:"command"`
+	Roots     []m.Root         `json:"roots"`
+	Policy    string           `json:"policy"`
+	Files     int              `json:"files"`
+	Divergent []DivergentFile  `json:"divergent"`
+	Conflicts []ConflictReport `json:"conflicts"`
+	Commands  []CommandReport  `json:"commands"`
+	Errors    []string         `json:"errors"`
+	Plan      m.Plan           `json:"-"`
+}
+
+type DivergentFile struct {
+	Path       string `json:"path"`
+	Hash       string `json:"hash"`
+	Divergence string `json:"divergence"`
+}
+
+type ConflictReport struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+}
+
+type CommandReport struct {
+	Command string   `json:"command"`
+	From    string   `json:"from"`
+	To      []string `json:"to,omitempty"`
+	Hash    string   `json:"hash,omitempty"`
+}
+
+func (c CommandReport) String() string {
+	if len(c.To) == 0 {
+		return fmt.Sprintf("%s %s", c.Command, c.From)
+	}
+	return fmt.Sprintf("%s %s -> %s", c.Command, c.From, strings.Join(c.To, ", "))
+}
+
+// maxSyncRounds bounds how often sync plans again for files the previous round set aside.
+const maxSyncRounds = 3
+
+// Headless scans the roots and reports, plans or syncs their divergence without rendering.
+func Headless(fs m.FS, events *stream.Stream[m.Event], roots []m.Root, policy m.Policy, command Command) (report *Report, err any) {
+	defer func() {
+		err = recover()
+	}()
+	c := newController(fs, roots, policy)
+	c.pull(events, func() bool { return c.analyzed })
+
+	if command == Status {
+		return c.report(command, nil), nil
+	}
+	if c.hashUnique(nil) {
+		c.pull(events, func() bool { return c.hashing == 0 })
+	}
+	if command == Plan {
+		commands := c.planAll()
+		report := c.report(command, commands)
+		report.Plan = c.exportPlan(commands)
+		return report, nil
+	}
+	applied := []m.FileCommand{}
+	for round := 0; round < maxSyncRounds && len(c.errors) == 0; round++ {
+		commands := c.planAll()
+		if len(commands) == 0 {
+			break
+		}
+		c.execute(commands)
+		c.pull(events, func() bool { return c.pending == 0 })
+		applied = append(applied, commands...)
+	}
+	return c.report(command, applied), nil
+}
+
+// ApplyPlan scans the roots of the plan and applies it if every source still matches.
+func ApplyPlan(fs m.FS, events *stream.Stream[m.Event], plan m.Plan) (report *Report, err any) {
+	defer func() {
+		err = recover()
+	}()
+	c := newController(fs, plan.Roots, m.OriginWins)
+	c.pull(events, func() bool { return c.analyzed })
+
+	commands, errs := c.verifyPlan(plan)
+	if len(errs) > 0 || len(c.errors) > 0 {
+		report := c.report(Apply, nil)
+		for _, err := range errs {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		return report, nil
+	}
+	c.execute(commands)
+	c.pull(events, func() bool { return c.pending == 0 })
+	return c.report(Apply, commands), nil
+}
+
+func (c *controller) planAll() []m.FileCommand {
+	p := c.newPlanner()
+	p.resolveFolder(c.roots[0], "")
+	return p.plan()
+}
+
+func (c *controller) pull(events *stream.Stream[m.Event], done func() bool) {
+	for !done() {
+		events, _ := events.Pull()
+		for _, event := range events {
+			c.handleEvent(event)
+		}
+	}
+}
+
+func (c *controller) report(command Command, commands []m.FileCommand) *Report {
+	report := &Report{
+		Command:   command.String(),
+		Roots:     c.roots,
+		Policy:    c.policy.String(),
+		Divergent: []DivergentFile{},
+		Conflicts: []ConflictReport{},
+		Commands:  []CommandReport{},
+		Errors:    []string{},
+	}
+	files := []*m.File{}
+	for _, archive := range c.archives {
+		for _, folder := range archive.folders {
+			for _, file := range folder.files {
+				files = append(files, file)
+			}
+		}
+	}
+	slices.SortFunc(files, func(a, b *m.File) int {
+		if result := cmp.Compare(c.archives[a.Root].idx, c.archives[b.Root].idx); result != 0 {
+			return result
+		}
+		return cmpIds(a.Id, b.Id)
+	})
+	for _, file := range files {
+		report.Files++
+		switch file.State {
+		case m.Divergent:
+			report.Divergent = append(report.Divergent, DivergentFile{
+				Path:       file.Id.String(),
+				Hash:       file.Hash.String(),
+				Divergence: file.Divergence.String(),
+			})
+		case m.Unique:
+			report.Divergent = append(report.Divergent, DivergentFile{
+				Path:       file.Id.String(),
+				Divergence: file.State.String(),
+			})
+		}
+	}
+	for _, conflict := range c.sortedConflicts() {
+		versions := []string{}
+		for _, file := range conflict.versions {
+			versions = append(versions, file.Id.String())
+		}
+		report.Conflicts = append(report.Conflicts, ConflictReport{Name: conflict.name.String(), Versions: versions})
+	}
+	for _, cmd := range commands {
+		report.Commands = append(report.Commands, commandReport(cmd))
+	}
+	for _, err := range c.errors {
+		if err.Id == (m.Id{}) {
+			report.Errors = append(report.Errors, err.Error.Error())
+		} else {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", err.Id, err.Error))
+		}
+	}
+	return report
+}
+
+func commandReport(cmd m.FileCommand) CommandReport {
+	switch cmd := cmd.(type) {
+	case m.DeleteFile:
+		return CommandReport{Command: "delete", From: cmd.Id.String(), Hash: cmd.Hash.String()}
+
+	case m.RenameFile:
+		to := m.Id{Root: cmd.From.Root, Name: cmd.To}
+		return CommandReport{Command: "rename", From: cmd.From.String(), To: []string{to.String()}, Hash: cmd.Hash.String()}
+
+	case m.RenameFolder:
+		from := m.Id{Root: cmd.Root, Name: cmd.From.ParentName()}
+		to := m.Id{Root: cmd.Root, Name: cmd.To.ParentName()}
+		return CommandReport{Command: "move", From: from.String(), To: []string{to.String()}}
+
+	case m.CopyFile:
+		to := []string{}
+		for _, id := range cmd.To {
+			to = append(to, id.String())
+		}
+		return CommandReport{Command: "copy", From: cmd.From.String(), To: to, Hash: cmd.Hash.String()}
+	}
+	return CommandReport{Command: fmt.Sprint(cmd)}
+}
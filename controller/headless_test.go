@@ -0,0 +1,71 @@
+package controller
+
+import (
+	m "arc/model"
+	"arc/stream"
+	"testing"
+	"time"
+)
+
+// streamFs answers every scan and command through the event stream, like file_fs does.
+type streamFs struct {
+	events *stream.Stream[m.Event]
+	metas  map[m.Root]map[string]m.Hash
+}
+
+func (fs *streamFs) Scan(root m.Root) {
+	for name, hash := range fs.metas[root] {
+		id := m.Id{Root: root, Name: testName(name)}
+		fs.events.Push(m.FileScanned{Meta: m.Meta{Id: id, Size: uint64(len(hash)), ModTime: time.Unix(1, 0)}})
+		fs.events.Push(m.FileHashed{Id: id, Hash: hash})
+	}
+	fs.events.Push(m.ArchiveScanned{Root: root})
+	fs.events.Push(m.ArchiveHashed{Root: root})
+}
+
+func (fs *streamFs) LoadSnapshot(roots []m.Root) {
+	fs.events.Push(m.SnapshotLoaded{})
+}
+
+func (fs *streamFs) Send(cmd m.FileCommand) {
+	switch cmd := cmd.(type) {
+	case m.DeleteFile:
+		fs.events.Push(m.FileDeleted(cmd))
+	case m.RenameFile:
+		fs.events.Push(m.FileRenamed(cmd))
+	case m.RenameFolder:
+		fs.events.Push(m.FolderRenamed(cmd))
+	case m.CopyFile:
+		fs.events.Push(m.FileCopied(cmd))
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	metas := map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx", "a/y.txt": "yyyy"},
+		"copy 1": {"x.txt": "XXXX", "y.txt": "yyyy"},
+		"copy 2": {"x.txt": "xxxx", "a/y.txt": "yyyy"},
+	}
+	tests := []struct {
+		command   Command
+		divergent int
+		conflicts int
+		commands  int
+	}{
+		{Status, 6, 1, 0},
+		{Plan, 6, 1, 3},
+		{Sync, 0, 0, 4},
+	}
+	for _, test := range tests {
+		events := stream.NewStream[m.Event]("test")
+		report, err := Headless(&streamFs{events: events, metas: metas}, events, testRoots, m.OriginWins, test.command)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.command, err)
+		}
+		if len(report.Divergent) != test.divergent || len(report.Conflicts) != test.conflicts || len(report.Commands) != test.commands {
+			t.Errorf("%s: expected %d divergent, %d conflicts, %d commands, got %d, %d, %d: %v",
+				test.command, test.divergent, test.conflicts, test.commands,
+				len(report.Divergent), len(report.Conflicts), len(report.Commands), report.Commands)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+)
+
+func TestArchiveIdentified(t *testing.T) {
+	c := newTestController(nil)
+	photos := m.Identity{Id: "5f0c6f7e-1d2a-4b3c-8d4e-9f0a1b2c3d4e", Label: "Photos"}
+	c.handleEvent(m.ArchiveIdentified{Root: testRoots[0], Identity: photos})
+	if len(c.errors) != 0 {
+		t.Fatalf("unexpected errors %v", c.errors)
+	}
+	if label := c.view().Label; label != "Photos" {
+		t.Errorf("expected label Photos, got %q", label)
+	}
+
+	c.handleEvent(m.ArchiveIdentified{Root: testRoots[1], Identity: photos})
+	if len(c.errors) != 1 {
+		t.Errorf("expected the same archive under two roots to be an error, got %v", c.errors)
+	}
+}
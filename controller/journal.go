@@ -0,0 +1,158 @@
+package controller
+
+import (
+	m "arc/model"
+	"fmt"
+	"slices"
+)
+
+// undoing is a journal entry whose reverse commands are still running.
+type undoing struct {
+	id      string
+	pending int
+}
+
+func (c *controller) journaled(event m.Journaled) {
+	c.journal = append(c.journal, event.Entry)
+	c.session = event.Entry.Session
+	c.reversed(operationKey(event.Entry.Operation))
+}
+
+// undoKey names the result of a reverse command: file_fs journals a file
+// command only once it succeeded, and reports a restore only then.
+func undoKey(cmd m.FileCommand) string {
+	if restore, ok := cmd.(m.RestoreFile); ok {
+		return "restore " + restore.File.Id.String()
+	}
+	return operationKey(m.NewOperation(cmd, nil))
+}
+
+func operationKey(op m.Operation) string {
+	return fmt.Sprint(op.Op, op.From, op.To)
+}
+
+// reversed journals the undo of an entry once all of its reverse commands succeeded.
+func (c *controller) reversed(key string) {
+	undo, ok := c.undoing[key]
+	if !ok {
+		return
+	}
+	delete(c.undoing, key)
+	undo.pending--
+	if undo.pending == 0 {
+		c.shared.fs.Send(m.MarkUndone{Ids: []string{undo.id}})
+	}
+}
+
+// undo offers to reverse the last journaled operation, or every operation of this session.
+func (c *controller) undo(session bool) {
+	entries := m.Undoable(c.journal)
+	entries = slices.DeleteFunc(entries, func(entry m.JournalEntry) bool {
+		for _, undo := range c.undoing {
+			if undo.id == entry.Id {
+				return true
+			}
+		}
+		return false
+	})
+	if session {
+		entries = slices.DeleteFunc(entries, func(entry m.JournalEntry) bool {
+			return c.session == "" || entry.Session != c.session
+		})
+	} else if len(entries) > 0 {
+		entries = entries[len(entries)-1:]
+	}
+	if len(entries) == 0 {
+		return
+	}
+	message := fmt.Sprintf("Undo %s of %q?", entries[0].Op, entries[0].From.Id())
+	if session {
+		message = fmt.Sprintf("Undo %d operations of this session?", len(entries))
+	}
+	c.confirmation = &confirmation{
+		title:   "Undo",
+		message: message,
+		action:  func() { c.undoEntries(entries) },
+	}
+}
+
+// undoEntries reverses the entries, newest first. An entry is journaled as
+// undone when its reverse commands succeeded, so a failed one stays undoable.
+func (c *controller) undoEntries(entries []m.JournalEntry) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		commands, err := c.reverse(entries[i])
+		if err != nil {
+			c.errors = append(c.errors, m.Error{Id: entries[i].From.Id(), Error: err})
+			continue
+		}
+		if len(commands) == 0 {
+			c.shared.fs.Send(m.MarkUndone{Ids: []string{entries[i].Id}})
+			continue
+		}
+		undo := &undoing{id: entries[i].Id, pending: len(commands)}
+		files := []m.FileCommand{}
+		for _, cmd := range commands {
+			c.undoing[undoKey(cmd)] = undo
+			if restore, ok := cmd.(m.RestoreFile); ok {
+				c.shared.fs.Send(restore)
+			} else {
+				files = append(files, cmd)
+			}
+		}
+		c.execute(files)
+	}
+}
+
+// reverse returns the commands that undo the entry.
+func (c *controller) reverse(entry m.JournalEntry) ([]m.FileCommand, error) {
+	from := entry.From.Id()
+	switch entry.Op {
+	case "rename":
+		to := entry.To[0].Id()
+		if file := c.file(to); file == nil || file.Hash != entry.Hash {
+			return nil, fmt.Errorf("cannot undo the rename: %q has changed", to)
+		}
+		if c.file(from) != nil {
+			return nil, fmt.Errorf("cannot undo the rename: %q exists", from)
+		}
+		return []m.FileCommand{m.RenameFile{Hash: entry.Hash, From: to, To: from.Name}}, nil
+
+	case "move":
+		archive, ok := c.archives[entry.From.Root]
+		if !ok {
+			return nil, fmt.Errorf("cannot undo the move: %q is not scanned", entry.From.Root)
+		}
+		if _, ok := archive.folders[m.Path(entry.From.Name)]; ok {
+			return nil, fmt.Errorf("cannot undo the move: %q exists", entry.From.Name)
+		}
+		return []m.FileCommand{m.RenameFolder{Root: entry.From.Root, From: m.Path(entry.To[0].Name), To: m.Path(entry.From.Name)}}, nil
+
+	case "copy":
+		commands := []m.FileCommand{}
+		for _, to := range entry.To {
+			if file := c.file(to.Id()); file != nil && file.Hash == entry.Hash {
+				commands = append(commands, m.DeleteFile{Hash: entry.Hash, Id: to.Id()})
+			}
+		}
+		return commands, nil
+
+	case "restore":
+		if file := c.file(from); file == nil || file.Hash != entry.Hash {
+			return nil, fmt.Errorf("cannot undo the restore: %q has changed", from)
+		}
+		return []m.FileCommand{m.DeleteFile{Hash: entry.Hash, Id: from}}, nil
+
+	case "delete":
+		if c.file(from) != nil {
+			return nil, fmt.Errorf("cannot restore %q: the file exists", from)
+		}
+		return []m.FileCommand{m.RestoreFile{File: m.TrashedFile{
+			Id:      from,
+			Batch:   entry.Trash,
+			Hash:    entry.Hash,
+			Size:    entry.Size,
+			ModTime: entry.ModTime,
+		}}}, nil
+	}
+	return nil, fmt.Errorf("cannot undo %q", entry.Op)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	m "arc/model"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"b.txt": "bbbb"},
+		"copy 1": {"a.txt": "bbbb"},
+		"copy 2": {"a.txt": "bbbb"},
+	})
+	earlier := m.JournalEntry{Id: "s0-1", Session: "s0", Operation: m.Operation{
+		Op: "delete", From: m.PlanId{Root: "copy 1", Name: "x.txt"}, Hash: "xxxx"}}
+	rename := m.JournalEntry{Id: "s1-1", Session: "s1", Operation: m.Operation{
+		Op: "rename", From: m.PlanId{Root: "origin", Name: "a.txt"}, To: []m.PlanId{{Root: "origin", Name: "b.txt"}}, Hash: "bbbb"}}
+	delete := m.JournalEntry{Id: "s1-2", Session: "s1", Trash: "s1", Operation: m.Operation{
+		Op: "delete", From: m.PlanId{Root: "origin", Name: "c.txt"}, Hash: "cccc", Size: 4}}
+	c.handleEvent(m.SnapshotLoaded{Journal: []m.JournalEntry{earlier}})
+	c.handleEvent(m.Journaled{Entry: rename})
+	c.handleEvent(m.Journaled{Entry: delete})
+
+	c.handleEvent(m.Undo{Session: true})
+	c.handleEvent(m.Enter{})
+	commands := c.shared.fs.(*testFs).commands
+	if len(commands) != 2 {
+		t.Fatalf("expected a restore and a rename, got %v", commands)
+	}
+	if restore, ok := commands[0].(m.RestoreFile); !ok || restore.File.Id != testId("origin", "c.txt") || restore.File.Batch != "s1" {
+		t.Errorf("expected to restore c.txt first, got %v", commands[0])
+	}
+	if plan := planString(commands[1:2]); plan != "rename origin/b.txt -> a.txt" {
+		t.Errorf("expected to rename b.txt back, got %s", plan)
+	}
+	if file := c.file(testId("origin", "a.txt")); file == nil || file.State == m.Divergent {
+		t.Errorf("expected a.txt to be back in sync, got %v", file)
+	}
+
+	// The rename back succeeds and is journaled; the restore fails and reports nothing.
+	c.handleEvent(m.Journaled{Entry: m.JournalEntry{Id: "s2-1", Session: "s2", Operation: m.NewOperation(commands[1], nil)}})
+	commands = c.shared.fs.(*testFs).commands
+	if len(commands) != 3 {
+		t.Fatalf("expected the undo record of the rename, got %v", commands)
+	}
+	if undone, ok := commands[2].(m.MarkUndone); !ok || len(undone.Ids) != 1 || undone.Ids[0] != "s1-1" {
+		t.Errorf("expected to journal the undo of the rename only, got %v", commands[2])
+	}
+	c.handleEvent(m.Journaled{Entry: m.JournalEntry{Id: "s2-2", Session: "s2", Operation: m.Operation{Op: "undo"}, Undoes: []string{"s1-1"}}})
+
+	c.handleEvent(m.Undo{Session: true})
+	if c.confirmation == nil || strings.Contains(c.confirmation.message, "c.txt") || !strings.Contains(c.confirmation.message, "1 operations") {
+		t.Errorf("expected only the rename back to be offered while the restore runs, got %v", c.confirmation)
+	}
+	c.handleEvent(m.Cancel{})
+	c.handleEvent(m.FileRestored{File: commands[0].(m.RestoreFile).File})
+	commands = c.shared.fs.(*testFs).commands
+	if undone, ok := commands[len(commands)-1].(m.MarkUndone); !ok || len(undone.Ids) != 1 || undone.Ids[0] != "s1-2" {
+		t.Errorf("expected to journal the undo of the restored delete, got %v", commands[len(commands)-1])
+	}
+	c.handleEvent(m.Journaled{Entry: m.JournalEntry{Id: "s2-3", Session: "s2", Operation: m.Operation{Op: "undo"}, Undoes: []string{"s1-2"}}})
+	if undoable := m.Undoable(c.journal); len(undoable) != 2 || undoable[0].Id != "s0-1" || undoable[1].Id != "s2-1" {
+		t.Errorf("expected the earlier session and the rename back to be undoable, got %v", undoable)
+	}
+}
+
+func TestUndoFailed(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"a.txt": "aaaa"},
+		"copy 1": {"a.txt": "aaaa"},
+		"copy 2": {"a.txt": "aaaa"},
+	})
+	delete := m.JournalEntry{Id: "s1-1", Session: "s1", Trash: "s1", Operation: m.Operation{
+		Op: "delete", From: m.PlanId{Root: "origin", Name: "c.txt"}, Hash: "cccc", Size: 4}}
+	c.handleEvent(m.Journaled{Entry: delete})
+
+	c.handleEvent(m.Undo{})
+	c.handleEvent(m.Enter{})
+	// The trash batch was purged: the restore fails with an error and no FileRestored.
+	c.handleEvent(m.Error{Id: testId("origin", "c.txt"), Error: os.ErrNotExist})
+	for _, cmd := range c.shared.fs.(*testFs).commands {
+		if _, ok := cmd.(m.MarkUndone); ok {
+			t.Errorf("expected the failed undo not to be journaled, got %v", cmd)
+		}
+	}
+	if undoable := m.Undoable(c.journal); len(undoable) != 1 || undoable[0].Id != "s1-1" {
+		t.Errorf("expected the delete to stay undoable, got %v", undoable)
+	}
+}
+
+func TestUndoRestore(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"a.txt": "aaaa"},
+	})
+	purge := m.JournalEntry{Id: "s1-1", Session: "s1", Trash: "s0", Operation: m.Operation{
+		Op: "purge", From: m.PlanId{Root: "origin"}, Size: 4}}
+	restore := m.JournalEntry{Id: "s1-2", Session: "s1", Trash: "s0", Operation: m.Operation{
+		Op: "restore", From: m.PlanId{Root: "origin", Name: "a.txt"}, Hash: "aaaa", Size: 4}}
+	c.handleEvent(m.Journaled{Entry: restore})
+	c.handleEvent(m.Journaled{Entry: purge})
+
+	c.handleEvent(m.Undo{})
+	c.handleEvent(m.Enter{})
+	commands := c.shared.fs.(*testFs).commands
+	if plan := planString(commands); plan != "delete origin/a.txt" {
+		t.Errorf("expected to delete the restored file, skipping the purge, got %s", plan)
+	}
+}
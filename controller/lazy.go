@@ -0,0 +1,130 @@
+package controller
+
+import (
+	m "arc/model"
+)
+
+type partialKey struct {
+	size uint64
+	hash m.Hash
+}
+
+// requestHashes asks for the hashes of the files a lazy scan left without one.
+// Files whose size no other file has are unique without reading them; the
+// others get a partial hash first unless a hashed file has their size.
+func (c *controller) requestHashes() {
+	bySize := map[uint64]int{}
+	hashedSizes := map[uint64]bool{}
+	for size := range c.snapshot.sizes {
+		hashedSizes[size] = true
+	}
+	accepted := map[m.Id]bool{}
+	for _, ids := range c.accepted {
+		for _, id := range ids {
+			accepted[id] = true
+		}
+	}
+	unhashed := []*m.File{}
+	c.forEachFile(func(file *m.File) {
+		bySize[file.Size]++
+		if file.Hash != "" {
+			hashedSizes[file.Size] = true
+		} else if file.State == m.Scanned && !c.hashRequested[file.Id] {
+			unhashed = append(unhashed, file)
+		}
+	})
+
+	full, partial := []m.Id{}, []m.Id{}
+	for _, file := range unhashed {
+		switch {
+		case hashedSizes[file.Size] || accepted[file.Id]:
+			full = append(full, file.Id)
+		case bySize[file.Size] > 1:
+			partial = append(partial, file.Id)
+		default:
+			file.State = m.Unique
+		}
+	}
+	c.sendHashFiles(partial, true)
+	c.sendHashFiles(full, false)
+}
+
+// partialsHashed hashes in full the files whose partial hash matches another file's.
+func (c *controller) partialsHashed() {
+	groups := map[partialKey][]m.Id{}
+	for id, hash := range c.partials {
+		if file := c.file(id); file != nil {
+			key := partialKey{size: file.Size, hash: hash}
+			groups[key] = append(groups[key], id)
+		}
+	}
+	full := []m.Id{}
+	for _, ids := range groups {
+		if len(ids) > 1 {
+			full = append(full, ids...)
+		} else if file := c.file(ids[0]); file.Hash == "" {
+			file.State = m.Unique
+		}
+	}
+	c.partials = map[m.Id]m.Hash{}
+	c.sendHashFiles(full, false)
+}
+
+func (c *controller) sendHashFiles(ids []m.Id, partial bool) {
+	if len(ids) == 0 {
+		return
+	}
+	for _, id := range ids {
+		c.hashRequested[id] = true
+	}
+	c.hashing++
+	c.shared.fs.Send(m.HashFiles{Ids: ids, Partial: partial})
+}
+
+func (c *controller) filesHashed(event m.FilesHashed) {
+	c.hashing--
+	if event.Partial {
+		c.partialsHashed()
+	}
+	if c.hashing > 0 {
+		return
+	}
+	if !c.analyzed {
+		c.analyzeIfReady()
+		return
+	}
+	c.analyzeDiscrepancies()
+	c.updateConflicts()
+	c.storeSnapshotIfSynced()
+	if then := c.afterHashing; then != nil {
+		c.afterHashing = nil
+		then()
+	}
+}
+
+// hashUnique asks for the hashes of the unique files, so they can be planned,
+// and calls then once they arrive. It returns false if there are none.
+func (c *controller) hashUnique(then func()) bool {
+	ids := []m.Id{}
+	c.forEachFile(func(file *m.File) {
+		if file.State == m.Unique {
+			ids = append(ids, file.Id)
+		}
+	})
+	if len(ids) == 0 {
+		return false
+	}
+	c.afterHashing = then
+	c.sendHashFiles(ids, false)
+	return true
+}
+
+func (c *controller) forEachFile(f func(file *m.File)) {
+	for _, archive := range c.archives {
+		for _, folder := range archive.folders {
+			for _, file := range folder.files {
+				f(file)
+			}
+		}
+	}
+}
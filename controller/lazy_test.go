@@ -0,0 +1,76 @@
+package controller
+
+import (
+	m "arc/model"
+	"arc/stream"
+	"testing"
+	"time"
+)
+
+// lazyFs scans without hashing; the partial hash of a file is the first letter of its hash.
+type lazyFs struct {
+	streamFs
+	requests []m.HashFiles
+}
+
+func (fs *lazyFs) Scan(root m.Root) {
+	for name, hash := range fs.metas[root] {
+		id := m.Id{Root: root, Name: testName(name)}
+		fs.events.Push(m.FileScanned{Meta: m.Meta{Id: id, Size: uint64(len(hash)), ModTime: time.Unix(1, 0)}})
+	}
+	fs.events.Push(m.ArchiveScanned{Root: root})
+	fs.events.Push(m.ArchiveHashed{Root: root})
+}
+
+func (fs *lazyFs) Send(cmd m.FileCommand) {
+	request, ok := cmd.(m.HashFiles)
+	if !ok {
+		fs.streamFs.Send(cmd)
+		return
+	}
+	fs.requests = append(fs.requests, request)
+	for _, id := range request.Ids {
+		hash := fs.metas[id.Root][id.Name.String()]
+		if request.Partial {
+			fs.events.Push(m.PartialHashed{Id: id, Hash: hash[:1]})
+		} else {
+			fs.events.Push(m.FileHashed{Id: id, Hash: hash})
+		}
+	}
+	fs.events.Push(m.FilesHashed{Partial: request.Partial})
+}
+
+func TestLazyHashing(t *testing.T) {
+	metas := map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx", "u.txt": "uniqueone"},
+		"copy 1": {"x.txt": "xxxx", "p.txt": "pppp-1"},
+		"copy 2": {"x.txt": "xxxx", "q.txt": "qqqq-2"},
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := &lazyFs{streamFs: streamFs{events: events, metas: metas}}
+	report, err := Headless(fs, events, testRoots, m.OriginWins, Status)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fs.requests) != 2 || !fs.requests[0].Partial || len(fs.requests[0].Ids) != 5 || fs.requests[1].Partial || len(fs.requests[1].Ids) != 3 {
+		t.Errorf("expected partial hashes of 5 files and full hashes of 3, got %v", fs.requests)
+	}
+	if len(report.Divergent) != 3 {
+		t.Fatalf("expected 3 unique files, got %v", report.Divergent)
+	}
+	for _, file := range report.Divergent {
+		if file.Divergence != "Unique" || file.Hash != "" {
+			t.Errorf("expected %s to be unique and not hashed, got %v", file.Path, file)
+		}
+	}
+
+	events = stream.NewStream[m.Event]("test")
+	fs = &lazyFs{streamFs: streamFs{events: events, metas: metas}}
+	report, err = Headless(fs, events, testRoots, m.OriginWins, Sync)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Divergent) != 0 || len(report.Commands) != 3 {
+		t.Errorf("expected the unique files to be hashed and copied, got %v, %v", report.Divergent, report.Commands)
+	}
+}
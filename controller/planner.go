@@ -0,0 +1,521 @@
+package controller
+
+import (
+	m "arc/model"
+	"cmp"
+	"slices"
+)
+
+// planner decides which commands make files consistent across all roots.
+// It works on its own copy of the catalog, so planning has no side effects;
+// the controller applies the resulting commands.
+type planner struct {
+	roots     []m.Root
+	policyFor func(m.Path) m.Policy
+	snapshot  *snapshot
+	files     map[m.Id]*m.File
+	byHash    map[m.Hash][]m.Id
+	dirs      map[m.Root]map[m.Path]int
+	commands  []m.FileCommand
+}
+
+func newPlanner(roots []m.Root, archives map[m.Root]*archive, byHash map[m.Hash][]*m.File,
+	policyFor func(m.Path) m.Policy, snapshot *snapshot) *planner {
+
+	p := &planner{
+		roots:     roots,
+		policyFor: policyFor,
+		snapshot:  snapshot,
+		files:     map[m.Id]*m.File{},
+		byHash:    map[m.Hash][]m.Id{},
+		dirs:      map[m.Root]map[m.Path]int{},
+	}
+	for _, root := range roots {
+		p.dirs[root] = map[m.Path]int{}
+	}
+	for _, archive := range archives {
+		for _, folder := range archive.folders {
+			for _, file := range folder.files {
+				clone := *file
+				p.add(&clone)
+			}
+		}
+	}
+	for hash, files := range byHash {
+		ids := make([]m.Id, 0, len(files))
+		for _, file := range files {
+			ids = append(ids, file.Id)
+		}
+		p.byHash[hash] = ids
+	}
+	return p
+}
+
+func (p *planner) plan() []m.FileCommand {
+	return p.commands
+}
+
+// resolve propagates the changes made since the last sync. When there is
+// no common ancestor or the roots changed in conflicting ways, the policy
+// of the file's folder picks the truth among the competing copies.
+func (p *planner) resolve(id m.Id) {
+	file, ok := p.files[id]
+	if !ok || file.Hash == "" {
+		return
+	}
+	if p.resolveThreeWay(file) {
+		return
+	}
+	files := candidates(p.roots, file, p.sameHashFiles(file.Hash), p.lookup)
+	truth, _ := newPolicy(p.policyFor(file.Path)).pick(p.roots, files)
+	p.resolveFile(truth.Id)
+}
+
+func (p *planner) resolveThreeWay(file *m.File) bool {
+	if p.snapshot == nil {
+		return false
+	}
+
+	if names, ok := p.snapshot.byHash[file.Hash]; ok {
+		// Only a name of the content gone from some root tells of a move or a deletion.
+		name, gone := p.goneName(file, names)
+		if !gone {
+			return false
+		}
+		sameHash := p.sameHashFiles(file.Hash)
+		movedTo := map[m.Name]*m.File{}
+		for _, sameFile := range sameHash {
+			if !slices.Contains(names, sameFile.Name) {
+				movedTo[sameFile.Name] = sameFile
+			}
+		}
+		deleted := false
+		for _, root := range p.roots {
+			if other, ok := p.files[m.Id{Root: root, Name: name}]; ok && other.Hash == file.Hash {
+				continue
+			}
+			if slices.ContainsFunc(sameHash, func(moved *m.File) bool {
+				return moved.Root == root && movedTo[moved.Name] != nil
+			}) {
+				continue
+			}
+			if changed, ok := p.files[m.Id{Root: root, Name: name}]; ok {
+				if _, known := p.snapshot.byHash[changed.Hash]; known || changed.Hash == "" {
+					return false
+				}
+				return p.resolveThreeWay(changed)
+			}
+			deleted = true
+		}
+
+		switch {
+		case deleted && len(movedTo) == 0:
+			for _, sameFile := range sameHash {
+				if sameFile.Name == name {
+					p.delete(sameFile.Id)
+				}
+			}
+			return true
+
+		case !deleted && len(movedTo) == 1:
+			for _, moved := range movedTo {
+				if len(names) == 1 {
+					p.resolveFile(moved.Id)
+					return true
+				}
+				return p.moveCopy(name, moved)
+			}
+		}
+		return false
+	}
+
+	if _, ok := p.snapshot.byName[file.Name]; !ok {
+		for _, root := range p.roots {
+			if other, ok := p.files[m.Id{Root: root, Name: file.Name}]; ok && other.Hash != file.Hash {
+				return false
+			}
+		}
+	} else {
+		for _, root := range p.roots {
+			other, ok := p.files[m.Id{Root: root, Name: file.Name}]
+			if ok && other.Hash != file.Hash && !p.snapshot.isAncestor(other) {
+				return false
+			}
+		}
+	}
+	p.resolveFile(file.Id)
+	return true
+}
+
+// goneName returns the name of the file's content in the snapshot that some
+// root lacks, preferring the file's own name.
+func (p *planner) goneName(file *m.File, names []m.Name) (m.Name, bool) {
+	names = slices.Clone(names)
+	slices.SortFunc(names, func(a, b m.Name) int { return cmp.Compare(a.String(), b.String()) })
+	if slices.Contains(names, file.Name) {
+		names = append([]m.Name{file.Name}, names...)
+	}
+	for _, name := range names {
+		for _, root := range p.roots {
+			if other, ok := p.files[m.Id{Root: root, Name: name}]; !ok || other.Hash != file.Hash {
+				return name, true
+			}
+		}
+	}
+	return m.Name{}, false
+}
+
+// moveCopy moves one of several copies of the content from the name to the
+// name of the moved file, leaving the other copies alone.
+func (p *planner) moveCopy(name m.Name, moved *m.File) bool {
+	for _, root := range p.roots {
+		if other, ok := p.files[m.Id{Root: root, Name: moved.Name}]; ok && other.Hash != moved.Hash {
+			return false
+		}
+	}
+	copyTo := []m.Id{}
+	for _, root := range p.roots {
+		if _, ok := p.files[m.Id{Root: root, Name: moved.Name}]; ok {
+			continue
+		}
+		p.clearPath(root, moved.Path)
+		if other, ok := p.files[m.Id{Root: root, Name: name}]; ok && other.Hash == moved.Hash {
+			p.rename(other.Id, moved.Name)
+		} else {
+			copyTo = append(copyTo, m.Id{Root: root, Name: moved.Name})
+		}
+	}
+	if len(copyTo) > 0 {
+		p.copy(moved.Id, copyTo)
+	}
+	return true
+}
+
+func (p *planner) sameHashFiles(hash m.Hash) []*m.File {
+	result := []*m.File{}
+	for _, id := range p.byHash[hash] {
+		result = append(result, p.files[id])
+	}
+	return result
+}
+
+func (p *planner) lookup(id m.Id) *m.File {
+	return p.files[id]
+}
+
+// resolveFile makes the file with the given id the truth for its content
+// and name in every root.
+func (p *planner) resolveFile(id m.Id) {
+	file, ok := p.files[id]
+	if !ok || file.Hash == "" {
+		return
+	}
+	hash := file.Hash
+	id = p.clearName(id)
+	name := id.Name
+
+	for _, root := range p.roots {
+		other, ok := p.files[m.Id{Root: root, Name: name}]
+		if ok && other.Hash != hash {
+			if p.snapshot.isAncestor(other) {
+				p.delete(other.Id)
+			} else {
+				p.rename(other.Id, p.uniqueName(name))
+			}
+		}
+	}
+
+	copyTo := []m.Id{}
+	for _, root := range p.roots {
+		p.clearPath(root, name.Path)
+		sameHash := p.sameHash(hash, root)
+		if len(sameHash) == 0 {
+			copyTo = append(copyTo, m.Id{Root: root, Name: name})
+			continue
+		}
+		keep := sameHash[0]
+		for _, sameId := range sameHash {
+			if sameId.Name == name {
+				keep = sameId
+			}
+		}
+		if keep.Name != name && p.moveFolder(keep, id) {
+			sameHash = p.sameHash(hash, root)
+			keep = m.Id{Root: root, Name: name}
+		}
+		if keep.Name != name {
+			p.rename(keep, name)
+		}
+		for _, sameId := range sameHash {
+			if sameId != keep {
+				p.delete(sameId)
+			}
+		}
+	}
+	if len(copyTo) > 0 {
+		p.copy(id, copyTo)
+	}
+}
+
+// keepVersion replaces every other version with the same name by the file.
+func (p *planner) keepVersion(id m.Id) {
+	file, ok := p.files[id]
+	if !ok {
+		return
+	}
+	for _, root := range p.roots {
+		if other, ok := p.files[m.Id{Root: root, Name: file.Name}]; ok && other.Hash != file.Hash {
+			p.delete(other.Id)
+		}
+	}
+	p.resolveFile(id)
+}
+
+// keepAll keeps every version with the name: the first one under the name,
+// the others under suffixed names, and brings all of them to every root.
+func (p *planner) keepAll(name m.Name) {
+	hashes := []m.Hash{}
+	versions := map[m.Hash][]m.Id{}
+	for _, root := range p.roots {
+		if file, ok := p.files[m.Id{Root: root, Name: name}]; ok && file.Hash != "" {
+			if _, ok := versions[file.Hash]; !ok {
+				hashes = append(hashes, file.Hash)
+			}
+			versions[file.Hash] = append(versions[file.Hash], file.Id)
+		}
+	}
+	keep := []m.Id{}
+	for i, hash := range hashes {
+		ids := versions[hash]
+		if i == 0 {
+			keep = append(keep, ids[0])
+			continue
+		}
+		newName := p.uniqueName(name)
+		for _, id := range ids {
+			p.rename(id, newName)
+		}
+		keep = append(keep, m.Id{Root: ids[0].Root, Name: newName})
+	}
+	for _, id := range keep {
+		p.resolveFile(id)
+	}
+}
+
+// resolveFolder resolves every divergent file under the path,
+// starting with the files of the given root.
+func (p *planner) resolveFolder(root m.Root, path m.Path) {
+	ids := []m.Id{}
+	for id, file := range p.files {
+		if file.State == m.Divergent && isUnder(id.Path, path) {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b m.Id) int {
+		if a.Root != b.Root && (a.Root == root || b.Root == root) {
+			if a.Root == root {
+				return -1
+			}
+			return 1
+		}
+		return cmpIds(a, b)
+	})
+	for _, id := range ids {
+		if file, ok := p.files[id]; ok && file.State == m.Divergent {
+			p.resolve(id)
+		}
+	}
+}
+
+func (p *planner) sameHash(hash m.Hash, root m.Root) []m.Id {
+	result := []m.Id{}
+	for _, id := range p.byHash[hash] {
+		if id.Root == root {
+			result = append(result, id)
+		}
+	}
+	slices.SortFunc(result, cmpIds)
+	return result
+}
+
+func (p *planner) clearName(id m.Id) m.Id {
+	if !p.nameCollidesWithPath(id.Name) {
+		return id
+	}
+	var newName m.Name
+	for i := 1; ; i++ {
+		newName = newSuffix(id.Name, i)
+		if !p.nameCollidesWithPath(newName) && !p.nameTaken(newName) {
+			break
+		}
+	}
+	p.rename(id, newName)
+	return m.Id{Root: id.Root, Name: newName}
+}
+
+func (p *planner) clearPath(root m.Root, path m.Path) {
+	for ; path != ""; path = path.ParentName().Path {
+		blocker := m.Id{Root: root, Name: path.ParentName()}
+		if _, ok := p.files[blocker]; ok {
+			p.rename(blocker, p.uniqueName(blocker.Name))
+		}
+	}
+}
+
+func (p *planner) nameCollidesWithPath(name m.Name) bool {
+	path := name.ChildPath()
+	for _, root := range p.roots {
+		if p.dirs[root][path] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *planner) nameTaken(name m.Name) bool {
+	for _, root := range p.roots {
+		if _, ok := p.files[m.Id{Root: root, Name: name}]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *planner) uniqueName(name m.Name) m.Name {
+	for i := 1; ; i++ {
+		newName := newSuffix(name, i)
+		if !p.nameTaken(newName) && !p.nameCollidesWithPath(newName) {
+			return newName
+		}
+	}
+}
+
+// moveFolder moves the folder holding the copy when the whole folder,
+// and not only the copy, reappears in the truth's root under another path.
+// It picks the topmost such folder.
+func (p *planner) moveFolder(copy, truth m.Id) bool {
+	if copy.Base != truth.Base {
+		return false
+	}
+	from, to := copy.Path, truth.Path
+	var moveFrom, moveTo m.Path
+	for from != "" && to != "" && from != to {
+		if p.canMoveFolder(copy.Root, from, to, truth.Root) {
+			moveFrom, moveTo = from, to
+		}
+		fromName, toName := from.ParentName(), to.ParentName()
+		if fromName.Base != toName.Base {
+			break
+		}
+		from, to = fromName.Path, toName.Path
+	}
+	if moveFrom == "" {
+		return false
+	}
+
+	p.commands = append(p.commands, m.RenameFolder{Root: copy.Root, From: moveFrom, To: moveTo})
+	moved := []*m.File{}
+	for id, file := range p.files {
+		if id.Root == copy.Root && isUnder(id.Path, moveFrom) {
+			moved = append(moved, file)
+		}
+	}
+	for _, file := range moved {
+		p.remove(file.Id)
+		file.Path = m.Path(string(moveTo) + string(file.Path[len(moveFrom):]))
+		file.State = m.Pending
+		p.add(file)
+		p.byHash[file.Hash] = append(p.byHash[file.Hash], file.Id)
+	}
+	return true
+}
+
+func (p *planner) canMoveFolder(root m.Root, from, to m.Path, truthRoot m.Root) bool {
+	if isUnder(to, from) || isUnder(from, to) || p.dirs[root][to] > 0 || p.dirs[root][from] == 0 {
+		return false
+	}
+	if _, ok := p.files[m.Id{Root: root, Name: to.ParentName()}]; ok {
+		return false
+	}
+	for id, file := range p.files {
+		if id.Root != root || !isUnder(id.Path, from) {
+			continue
+		}
+		path := m.Path(string(to) + string(id.Path[len(from):]))
+		truth, ok := p.files[m.Id{Root: truthRoot, Name: m.Name{Path: path, Base: id.Base}}]
+		if !ok || truth.Hash != file.Hash || file.Hash == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *planner) rename(from m.Id, to m.Name) {
+	file := p.files[from]
+	p.commands = append(p.commands, m.RenameFile{Hash: file.Hash, From: from, To: to})
+	p.remove(from)
+	file.Name = to
+	file.State = m.Pending
+	p.add(file)
+	p.byHash[file.Hash] = append(p.byHash[file.Hash], file.Id)
+}
+
+func (p *planner) delete(id m.Id) {
+	file := p.files[id]
+	p.commands = append(p.commands, m.DeleteFile{Hash: file.Hash, Id: id})
+	p.remove(id)
+}
+
+func (p *planner) copy(from m.Id, to []m.Id) {
+	file := p.files[from]
+	p.commands = append(p.commands, m.CopyFile{Hash: file.Hash, From: from, To: to})
+	file.State = m.Pending
+	for _, toId := range to {
+		clone := *file
+		clone.Id = toId
+		p.add(&clone)
+		p.byHash[file.Hash] = append(p.byHash[file.Hash], toId)
+	}
+}
+
+func (p *planner) add(file *m.File) {
+	p.files[file.Id] = file
+	for path := file.Path; ; path = path.ParentName().Path {
+		p.dirs[file.Root][path]++
+		if path == "" {
+			break
+		}
+	}
+}
+
+func (p *planner) remove(id m.Id) {
+	file := p.files[id]
+	delete(p.files, id)
+	for path := id.Path; ; path = path.ParentName().Path {
+		p.dirs[id.Root][path]--
+		if path == "" {
+			break
+		}
+	}
+	ids := p.byHash[file.Hash]
+	if idx := slices.Index(ids, id); idx >= 0 {
+		p.byHash[file.Hash] = slices.Delete(ids, idx, idx+1)
+	}
+}
+
+func isUnder(path, folder m.Path) bool {
+	if folder == "" || path == folder {
+		return true
+	}
+	return len(path) > len(folder) && path[:len(folder)] == folder && path[len(folder)] == '/'
+}
+
+func cmpIds(a, b m.Id) int {
+	if result := cmp.Compare(a.Root, b.Root); result != 0 {
+		return result
+	}
+	if result := cmp.Compare(a.Path, b.Path); result != 0 {
+		return result
+	}
+	return cmp.Compare(a.Base, b.Base)
+}
@@ -0,0 +1,310 @@
+package controller
+
+import (
+	m "arc/model"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRoots = []m.Root{"origin", "copy 1", "copy 2"}
+
+func newTestController(metas map[m.Root]map[string]m.Hash, snapshot ...m.SnapshotFile) *controller {
+	c := &controller{
+		roots:    testRoots,
+		archives: map[m.Root]*archive{},
+		byHash:   map[m.Hash][]*m.File{},
+		policies: map[m.Path]m.Policy{},
+		accepted: map[m.Hash][]m.Id{},
+
+		hashRequested: map[m.Id]bool{},
+		partials:      map[m.Id]m.Hash{},
+		undoing:       map[string]*undoing{},
+		shared:        &shared{fs: &testFs{}},
+
+		conflicts:        map[m.Name]*conflict{},
+		skippedConflicts: map[m.Name]bool{},
+	}
+	for idx, root := range testRoots {
+		c.archives[root] = newArchive(root, idx, c.shared)
+	}
+	c.archive = c.archives[testRoots[0]]
+	for _, root := range testRoots {
+		for name, hash := range metas[root] {
+			id := m.Id{Root: root, Name: testName(name)}
+			c.handleEvent(m.FileScanned{Meta: m.Meta{Id: id, Size: uint64(len(hash)), ModTime: time.Unix(1, 0)}})
+			c.handleEvent(m.FileHashed{Id: id, Hash: hash})
+		}
+	}
+	c.handleEvent(m.SnapshotLoaded{Files: snapshot})
+	for _, root := range testRoots {
+		c.handleEvent(m.ArchiveHashed{Root: root})
+	}
+	return c
+}
+
+type testFs struct {
+	commands []m.FileCommand
+}
+
+func (fs *testFs) Scan(root m.Root)            {}
+func (fs *testFs) LoadSnapshot(roots []m.Root) {}
+func (fs *testFs) Send(cmd m.FileCommand)      { fs.commands = append(fs.commands, cmd) }
+
+func testName(name string) m.Name {
+	path := m.Path(filepath.Dir(name))
+	if path == "." {
+		path = ""
+	}
+	return m.Name{Path: path, Base: m.Base(filepath.Base(name))}
+}
+
+func testId(root m.Root, name string) m.Id {
+	return m.Id{Root: root, Name: testName(name)}
+}
+
+func planString(commands []m.FileCommand) string {
+	lines := []string{}
+	for _, cmd := range commands {
+		switch cmd := cmd.(type) {
+		case m.RenameFile:
+			lines = append(lines, fmt.Sprintf("rename %s -> %s", cmd.From, cmd.To))
+		case m.RenameFolder:
+			lines = append(lines, fmt.Sprintf("move %s/%s -> %s", cmd.Root, cmd.From, cmd.To))
+		case m.DeleteFile:
+			lines = append(lines, fmt.Sprintf("delete %s", cmd.Id))
+		case m.CopyFile:
+			to := []string{}
+			for _, id := range cmd.To {
+				to = append(to, id.String())
+			}
+			lines = append(lines, fmt.Sprintf("copy %s -> %s", cmd.From, strings.Join(to, ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPlannerResolveFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		metas map[m.Root]map[string]m.Hash
+		file  m.Id
+		plan  string
+	}{
+		{
+			name: "missing",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"a/x.txt": "xxxx"},
+				"copy 1": {"a/x.txt": "xxxx"},
+			},
+			file: testId("origin", "a/x.txt"),
+			plan: "copy origin/a/x.txt -> copy 2/a/x.txt",
+		},
+		{
+			name: "renamed",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 1": {"y.txt": "xxxx"},
+				"copy 2": {"x.txt": "xxxx"},
+			},
+			file: testId("origin", "x.txt"),
+			plan: "rename copy 1/y.txt -> x.txt",
+		},
+		{
+			name: "same name, different content",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 1": {"x.txt": "yyyy"},
+				"copy 2": {"x.txt": "xxxx"},
+			},
+			file: testId("origin", "x.txt"),
+			plan: "rename copy 1/x.txt -> x`1.txt\ncopy origin/x.txt -> copy 1/x.txt",
+		},
+		{
+			name: "duplicates",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 1": {"x.txt": "xxxx", "b/x.txt": "xxxx"},
+				"copy 2": {"x.txt": "xxxx"},
+			},
+			file: testId("origin", "x.txt"),
+			plan: "delete copy 1/b/x.txt",
+		},
+		{
+			name: "file in the way of a folder",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"a/b/c/d": "abcd", "b/0000": "0000"},
+				"copy 1": {"a/b": "abcd", "b/0000": "0000"},
+				"copy 2": {"b/0000": "0000"},
+			},
+			file: testId("origin", "a/b/c/d"),
+			plan: "rename copy 1/a/b -> a/b`1\nrename copy 1/a/b`1 -> a/b/c/d\ncopy origin/a/b/c/d -> copy 2/a/b/c/d",
+		},
+		{
+			name: "name collides with folder",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"a/b/c/d": "abcd", "b/0000": "0000"},
+				"copy 1": {"a/b": "abcd", "b/0000": "0000"},
+				"copy 2": {"b/0000": "0000"},
+			},
+			file: testId("copy 1", "a/b"),
+			plan: "rename copy 1/a/b -> a/b`1\nrename origin/a/b/c/d -> a/b`1\ncopy copy 1/a/b`1 -> copy 2/a/b`1",
+		},
+	}
+	for _, test := range tests {
+		c := newTestController(test.metas)
+		p := c.newPlanner()
+		p.resolveFile(test.file)
+		if plan := planString(p.plan()); plan != test.plan {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", test.name, test.plan, plan)
+		}
+	}
+}
+
+func TestPlannerMovedFolder(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"new/2020/a.jpg": "aaaa", "new/2020/b.jpg": "bbbb", "new/c.jpg": "cccc"},
+		"copy 1": {"old/2020/a.jpg": "aaaa", "old/2020/b.jpg": "bbbb", "old/c.jpg": "cccc"},
+		"copy 2": {"new/2020/a.jpg": "aaaa", "photos/2020/b.jpg": "bbbb", "new/c.jpg": "cccc"},
+	})
+	p := c.newPlanner()
+	p.resolveFolder("origin", "")
+	expected := "move copy 1/old -> new\nrename copy 2/photos/2020/b.jpg -> new/2020/b.jpg"
+	if plan := planString(p.plan()); plan != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, plan)
+	}
+
+	c.execute(p.plan())
+	for _, name := range []string{"new/2020/a.jpg", "new/2020/b.jpg", "new/c.jpg"} {
+		if file := c.file(testId("copy 1", name)); file == nil || file.State != m.Pending {
+			t.Errorf("expected %q to be pending", name)
+		}
+	}
+}
+
+func TestPlannerHasNoSideEffects(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx"},
+		"copy 1": {"y.txt": "xxxx"},
+	})
+	p := c.newPlanner()
+	p.resolveFile(testId("origin", "x.txt"))
+	if len(p.plan()) == 0 {
+		t.Fatal("expected a plan")
+	}
+	if c.file(testId("copy 1", "y.txt")) == nil || c.file(testId("copy 1", "x.txt")) != nil {
+		t.Error("planner modified the catalog")
+	}
+	if len(c.byHash["xxxx"]) != 2 {
+		t.Error("planner modified byHash")
+	}
+}
+
+func TestPlannerResolveFolder(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"a/x.txt": "xxxx", "a/b/y.txt": "yyyy", "z.txt": "zzzz"},
+		"copy 1": {"a/x.txt": "xxxx", "a/b/y.txt": "yyyy", "z.txt": "zzzz"},
+		"copy 2": {"a/x.txt": "xxxx"},
+	})
+	p := c.newPlanner()
+	p.resolveFolder("origin", "a")
+	expected := "copy origin/a/b/y.txt -> copy 2/a/b/y.txt"
+	if plan := planString(p.plan()); plan != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, plan)
+	}
+}
+
+func TestPlannerThreeWay(t *testing.T) {
+	ancestor := []m.SnapshotFile{{Name: testName("x.txt"), Hash: "xxxx"}}
+	duplicates := []m.SnapshotFile{{Name: testName("a.txt"), Hash: "xxxx"}, {Name: testName("b.txt"), Hash: "xxxx"}}
+	tests := []struct {
+		name     string
+		metas    map[m.Root]map[string]m.Hash
+		snapshot []m.SnapshotFile
+		file     m.Id
+		plan     string
+	}{
+		{
+			name: "deleted in copy 1",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 2": {"x.txt": "xxxx"},
+			},
+			snapshot: ancestor,
+			file:     testId("origin", "x.txt"),
+			plan:     "delete origin/x.txt\ndelete copy 2/x.txt",
+		},
+		{
+			name: "added in origin",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+			},
+			file: testId("origin", "x.txt"),
+			plan: "copy origin/x.txt -> copy 1/x.txt, copy 2/x.txt",
+		},
+		{
+			name: "renamed in copy 1",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 1": {"a/y.txt": "xxxx"},
+				"copy 2": {"x.txt": "xxxx"},
+			},
+			snapshot: ancestor,
+			file:     testId("origin", "x.txt"),
+			plan:     "rename origin/x.txt -> a/y.txt\nrename copy 2/x.txt -> a/y.txt",
+		},
+		{
+			name: "modified in copy 2",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 1": {"x.txt": "xxxx"},
+				"copy 2": {"x.txt": "yyyy"},
+			},
+			snapshot: ancestor,
+			file:     testId("origin", "x.txt"),
+			plan:     "delete origin/x.txt\ndelete copy 1/x.txt\ncopy copy 2/x.txt -> origin/x.txt, copy 1/x.txt",
+		},
+		{
+			name: "deleted in copy 1 and renamed in copy 2",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"x.txt": "xxxx"},
+				"copy 2": {"y.txt": "xxxx"},
+			},
+			snapshot: ancestor,
+			file:     testId("origin", "x.txt"),
+			plan:     "rename copy 2/y.txt -> x.txt\ncopy origin/x.txt -> copy 1/x.txt",
+		},
+		{
+			name: "one of two copies deleted in origin",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"b.txt": "xxxx"},
+				"copy 1": {"a.txt": "xxxx", "b.txt": "xxxx"},
+				"copy 2": {"a.txt": "xxxx", "b.txt": "xxxx"},
+			},
+			snapshot: duplicates,
+			file:     testId("copy 1", "a.txt"),
+			plan:     "delete copy 1/a.txt\ndelete copy 2/a.txt",
+		},
+		{
+			name: "one of two copies renamed in copy 1",
+			metas: map[m.Root]map[string]m.Hash{
+				"origin": {"a.txt": "xxxx", "b.txt": "xxxx"},
+				"copy 1": {"b.txt": "xxxx", "c.txt": "xxxx"},
+				"copy 2": {"a.txt": "xxxx", "b.txt": "xxxx"},
+			},
+			snapshot: duplicates,
+			file:     testId("origin", "a.txt"),
+			plan:     "rename origin/a.txt -> c.txt\nrename copy 2/a.txt -> c.txt",
+		},
+	}
+	for _, test := range tests {
+		c := newTestController(test.metas, test.snapshot...)
+		p := c.newPlanner()
+		p.resolve(test.file)
+		if plan := planString(p.plan()); plan != test.plan {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", test.name, test.plan, plan)
+		}
+	}
+}
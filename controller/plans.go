@@ -0,0 +1,170 @@
+package controller
+
+import (
+	m "arc/model"
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// planFiles follows the files through the commands of a plan without touching the archives.
+type planFiles map[m.Id]m.File
+
+func (c *controller) planFiles() planFiles {
+	files := planFiles{}
+	for _, archive := range c.archives {
+		for _, folder := range archive.folders {
+			for _, file := range folder.files {
+				files[file.Id] = *file
+			}
+		}
+	}
+	return files
+}
+
+func (f planFiles) apply(cmd m.FileCommand) {
+	switch cmd := cmd.(type) {
+	case m.DeleteFile:
+		delete(f, cmd.Id)
+
+	case m.RenameFile:
+		if file, ok := f[cmd.From]; ok {
+			delete(f, cmd.From)
+			file.Name = cmd.To
+			f[file.Id] = file
+		}
+
+	case m.RenameFolder:
+		moved := []m.File{}
+		for id, file := range f {
+			if id.Root == cmd.Root && isUnder(id.Path, cmd.From) {
+				moved = append(moved, file)
+				delete(f, id)
+			}
+		}
+		for _, file := range moved {
+			file.Path = m.Path(string(cmd.To) + string(file.Path[len(cmd.From):]))
+			f[file.Id] = file
+		}
+
+	case m.CopyFile:
+		if file, ok := f[cmd.From]; ok {
+			for _, to := range cmd.To {
+				file.Id = to
+				f[to] = file
+			}
+		}
+	}
+}
+
+// folder returns the files under the folder of the root, by name.
+func (f planFiles) folder(root m.Root, path m.Path) []m.PlanFile {
+	result := []m.PlanFile{}
+	for id, file := range f {
+		if id.Root == root && isUnder(id.Path, path) {
+			result = append(result, m.PlanFile{Name: id.Name.String(), Hash: file.Hash, Size: file.Size, ModTime: file.ModTime})
+		}
+	}
+	slices.SortFunc(result, func(a, b m.PlanFile) int { return cmp.Compare(a.Name, b.Name) })
+	return result
+}
+
+// source is the file the command reads.
+func source(cmd m.FileCommand) (m.Id, bool) {
+	switch cmd := cmd.(type) {
+	case m.DeleteFile:
+		return cmd.Id, true
+	case m.RenameFile:
+		return cmd.From, true
+	case m.CopyFile:
+		return cmd.From, true
+	}
+	return m.Id{}, false
+}
+
+func (c *controller) exportPlan(commands []m.FileCommand) m.Plan {
+	plan := m.Plan{Roots: c.roots, Operations: []m.Operation{}}
+	files := c.planFiles()
+	for _, cmd := range commands {
+		var sourceFile *m.File
+		if id, ok := source(cmd); ok {
+			if file, ok := files[id]; ok {
+				sourceFile = &file
+			}
+		}
+		op := m.NewOperation(cmd, sourceFile)
+		if move, ok := cmd.(m.RenameFolder); ok {
+			op.Files = files.folder(move.Root, move.From)
+		}
+		plan.Operations = append(plan.Operations, op)
+		files.apply(cmd)
+	}
+	return plan
+}
+
+// verifyPlan checks that every source still matches what the plan recorded;
+// a moved folder must hold the same files as it did.
+func (c *controller) verifyPlan(plan m.Plan) ([]m.FileCommand, []error) {
+	commands := []m.FileCommand{}
+	errs := []error{}
+	files := c.planFiles()
+	for _, op := range plan.Operations {
+		cmd, err := op.Command()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if id, ok := source(cmd); ok {
+			if err := files.verify(id, m.PlanFile{Hash: op.Hash, Size: op.Size, ModTime: op.ModTime}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if move, ok := cmd.(m.RenameFolder); ok {
+			errs = append(errs, files.verifyFolder(move, op.Files)...)
+		}
+		files.apply(cmd)
+		commands = append(commands, cmd)
+	}
+	return commands, errs
+}
+
+func (f planFiles) verify(id m.Id, expected m.PlanFile) error {
+	file, ok := f[id]
+	switch {
+	case !ok:
+		return fmt.Errorf("%s: file not found", id)
+	case file.Hash != expected.Hash:
+		return fmt.Errorf("%s: hash is %s, plan expects %s", id, file.Hash, expected.Hash)
+	case file.Size != expected.Size:
+		return fmt.Errorf("%s: size is %d, plan expects %d", id, file.Size, expected.Size)
+	case !file.ModTime.Equal(expected.ModTime):
+		return fmt.Errorf("%s: modified at %s, plan expects %s", id, file.ModTime.Format(time.RFC3339), expected.ModTime.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (f planFiles) verifyFolder(move m.RenameFolder, expected []m.PlanFile) []error {
+	errs := []error{}
+	for _, file := range expected {
+		if err := f.verify(m.PlanId{Root: move.Root, Name: file.Name}.Id(), file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, file := range f.folder(move.Root, move.From) {
+		if !slices.ContainsFunc(expected, func(other m.PlanFile) bool { return other.Name == file.Name }) {
+			errs = append(errs, fmt.Errorf("%s: not in the moved folder of the plan", m.PlanId{Root: move.Root, Name: file.Name}.Id()))
+		}
+	}
+	return errs
+}
+
+func (c *controller) savePlan() {
+	path, err := filepath.Abs(fmt.Sprintf("arc-plan-%s.json", time.Now().Format("20060102-150405")))
+	if err != nil {
+		c.errors = append(c.errors, m.Error{Error: err})
+		return
+	}
+	c.shared.fs.Send(m.StorePlan{Path: path, Plan: c.exportPlan(c.review.kept())})
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	m "arc/model"
+	"encoding/json"
+	"testing"
+)
+
+func TestPlanExport(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx", "a/y.txt": "yyyy"},
+		"copy 1": {"x.txt": "XXXX", "y.txt": "yyyy"},
+		"copy 2": {"x.txt": "xxxx", "a/y.txt": "yyyy"},
+	})
+	commands := c.planAll()
+	data, err := json.Marshal(c.exportPlan(commands))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan m.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		t.Fatal(err)
+	}
+
+	verified, errs := c.verifyPlan(plan)
+	if len(errs) != 0 || planString(verified) != planString(commands) {
+		t.Errorf("expected the plan to round trip, got %v:\n%s", errs, planString(verified))
+	}
+
+	c.file(testId("origin", "x.txt")).Size++
+	if _, errs := c.verifyPlan(plan); len(errs) != 1 {
+		t.Errorf("expected the changed source to fail verification, got %v", errs)
+	}
+}
+
+func TestPlanVerifyMovedFolder(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"copy 1": {"a/y.txt": "yyyy", "a/z.txt": "zzzz"},
+	})
+	plan := c.exportPlan([]m.FileCommand{m.RenameFolder{Root: "copy 1", From: "a", To: "b"}})
+	if files := plan.Operations[0].Files; len(files) != 2 {
+		t.Fatalf("expected the files of the folder in the plan, got %v", files)
+	}
+	if _, errs := c.verifyPlan(plan); len(errs) != 0 {
+		t.Errorf("expected the plan to verify, got %v", errs)
+	}
+
+	c.file(testId("copy 1", "a/y.txt")).Hash = "YYYY"
+	if _, errs := c.verifyPlan(plan); len(errs) != 1 {
+		t.Errorf("expected the changed file to fail verification, got %v", errs)
+	}
+
+	plan.Operations[0].Files = plan.Operations[0].Files[1:]
+	c.file(testId("copy 1", "a/y.txt")).Hash = "yyyy"
+	if _, errs := c.verifyPlan(plan); len(errs) != 1 {
+		t.Errorf("expected the file missing from the plan to fail verification, got %v", errs)
+	}
+}
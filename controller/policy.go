@@ -0,0 +1,120 @@
+package controller
+
+import (
+	m "arc/model"
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// policy picks the copy that is considered the truth for a divergent file
+// and explains the choice.
+type policy interface {
+	pick(roots []m.Root, candidates []*m.File) (*m.File, string)
+}
+
+func newPolicy(p m.Policy) policy {
+	switch p {
+	case m.NewestWins:
+		return newestWins{}
+	case m.MajorityWins:
+		return majorityWins{}
+	}
+	return originWins{}
+}
+
+type originWins struct{}
+
+func (originWins) pick(roots []m.Root, candidates []*m.File) (*m.File, string) {
+	for _, candidate := range candidates {
+		if candidate.Root == roots[0] {
+			return candidate, fmt.Sprintf("%q is the origin", roots[0])
+		}
+	}
+	return candidates[0], fmt.Sprintf("no copy in origin %q, keeping the selected copy", roots[0])
+}
+
+type newestWins struct{}
+
+func (newestWins) pick(roots []m.Root, candidates []*m.File) (*m.File, string) {
+	newest := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.ModTime.After(newest.ModTime) {
+			newest = candidate
+		}
+	}
+	return newest, fmt.Sprintf("newest modification time %s", newest.ModTime.Format(time.DateTime))
+}
+
+type majorityWins struct{}
+
+func (majorityWins) pick(roots []m.Root, candidates []*m.File) (*m.File, string) {
+	type version struct {
+		m.Name
+		m.Hash
+	}
+	votes := map[version]map[m.Root]struct{}{}
+	for _, candidate := range candidates {
+		key := version{candidate.Name, candidate.Hash}
+		if votes[key] == nil {
+			votes[key] = map[m.Root]struct{}{}
+		}
+		votes[key][candidate.Root] = struct{}{}
+	}
+	winner := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if len(votes[version{candidate.Name, candidate.Hash}]) > len(votes[version{winner.Name, winner.Hash}]) {
+			winner = candidate
+		}
+	}
+	return winner, fmt.Sprintf("%d of %d archives agree on %q",
+		len(votes[version{winner.Name, winner.Hash}]), len(roots), winner.Name)
+}
+
+// candidates lists every copy competing with the selected file: the files with
+// the same hash and the files with the same name but different content.
+// The selected file goes first, the rest are ordered by root and name.
+func candidates(roots []m.Root, selected *m.File, sameHash []*m.File, lookup func(m.Id) *m.File) []*m.File {
+	result := []*m.File{}
+	for _, file := range sameHash {
+		if file.Id != selected.Id {
+			result = append(result, file)
+		}
+	}
+	for _, root := range roots {
+		other := lookup(m.Id{Root: root, Name: selected.Name})
+		if other != nil && other.Hash != selected.Hash {
+			result = append(result, other)
+		}
+	}
+	slices.SortFunc(result, func(a, b *m.File) int {
+		if result := cmp.Compare(slices.Index(roots, a.Root), slices.Index(roots, b.Root)); result != 0 {
+			return result
+		}
+		return cmpIds(a.Id, b.Id)
+	})
+	return append([]*m.File{selected}, result...)
+}
+
+func (c *controller) policyFor(path m.Path) m.Policy {
+	for {
+		if policy, ok := c.policies[path]; ok {
+			return policy
+		}
+		if path == "" {
+			return c.policy
+		}
+		path = path.ParentName().Path
+	}
+}
+
+func (c *controller) cyclePolicy() {
+	path := c.archive.currentPath
+	c.policies[path] = m.Policies[(int(c.policyFor(path))+1)%len(m.Policies)]
+}
+
+func (c *controller) pick(file *m.File) (*m.File, string) {
+	files := candidates(c.roots, file, c.byHash[file.Hash], c.file)
+	return newPolicy(c.policyFor(file.Path)).pick(c.roots, files)
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+	"time"
+)
+
+func TestPolicies(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx"},
+		"copy 1": {"x.txt": "yyyy"},
+		"copy 2": {"x.txt": "yyyy"},
+	})
+	c.file(testId("copy 2", "x.txt")).ModTime = time.Unix(2, 0)
+
+	tests := []struct {
+		policy m.Policy
+		truth  m.Id
+	}{
+		{m.OriginWins, testId("origin", "x.txt")},
+		{m.NewestWins, testId("copy 2", "x.txt")},
+		{m.MajorityWins, testId("copy 1", "x.txt")},
+	}
+	for _, test := range tests {
+		c.policy = test.policy
+		truth, reason := c.pick(c.file(testId("copy 1", "x.txt")))
+		if truth.Id != test.truth {
+			t.Errorf("%s: expected %q, got %q (%s)", test.policy, test.truth, truth.Id, reason)
+		}
+	}
+}
+
+func TestPolicyPerFolder(t *testing.T) {
+	c := newTestController(nil)
+	c.policy = m.NewestWins
+	c.policies["a"] = m.MajorityWins
+	if policy := c.policyFor("a/b"); policy != m.MajorityWins {
+		t.Errorf("expected %s, got %s", m.MajorityWins, policy)
+	}
+	if policy := c.policyFor("b"); policy != m.NewestWins {
+		t.Errorf("expected %s, got %s", m.NewestWins, policy)
+	}
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	m "arc/model"
+	"fmt"
+)
+
+// offerResume asks to finish the copies an earlier run left behind.
+// Copies resume from their last checkpoint whenever they are executed again,
+// so declining only postpones them.
+func (c *controller) offerResume() {
+	commands := c.resumeCommands()
+	c.interrupted = nil
+	if len(commands) == 0 || c.confirmation != nil {
+		return
+	}
+	c.confirmation = &confirmation{
+		title:   "Resume Copies",
+		message: fmt.Sprintf("Resume %d interrupted copies?", len(commands)),
+		action:  func() { c.apply(commands) },
+	}
+}
+
+// resumeCommands are the interrupted copies whose source is unchanged and whose target is still missing.
+func (c *controller) resumeCommands() []m.FileCommand {
+	byFrom := map[m.Id]*m.CopyFile{}
+	commands := []m.FileCommand{}
+	for _, copy := range c.interrupted {
+		source := c.file(copy.From)
+		if source == nil || source.Hash != copy.Hash || c.file(copy.To) != nil {
+			continue
+		}
+		if cmd, ok := byFrom[copy.From]; ok {
+			cmd.To = append(cmd.To, copy.To)
+			continue
+		}
+		byFrom[copy.From] = &m.CopyFile{Hash: copy.Hash, From: copy.From, To: []m.Id{copy.To}}
+	}
+	for _, copy := range c.interrupted {
+		if cmd, ok := byFrom[copy.From]; ok {
+			commands = append(commands, *cmd)
+			delete(byFrom, copy.From)
+		}
+	}
+	return commands
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+)
+
+func TestResume(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx", "y.txt": "yyyy"},
+		"copy 1": {"y.txt": "yyyy"},
+	})
+	c.interrupted = []m.InterruptedCopy{
+		{From: testId("origin", "x.txt"), To: testId("copy 1", "x.txt"), Hash: "xxxx", Committed: 10},
+		{From: testId("origin", "x.txt"), To: testId("copy 2", "x.txt"), Hash: "xxxx", Committed: 20},
+		{From: testId("origin", "y.txt"), To: testId("copy 1", "y.txt"), Hash: "yyyy", Committed: 30},
+		{From: testId("origin", "y.txt"), To: testId("copy 2", "y.txt"), Hash: "YYYY", Committed: 40},
+	}
+	c.offerResume()
+	if c.confirmation == nil {
+		t.Fatalf("expected an offer to resume")
+	}
+	c.handleEvent(m.Enter{})
+	if plan := planString(c.shared.fs.(*testFs).commands); plan != "copy origin/x.txt -> copy 1/x.txt, copy 2/x.txt" {
+		t.Errorf("expected to resume only the valid copies, got:\n%s", plan)
+	}
+}
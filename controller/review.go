@@ -0,0 +1,157 @@
+package controller
+
+import (
+	m "arc/model"
+	v "arc/view"
+	"fmt"
+)
+
+// planReview holds the commands of a dry run until the user applies or discards them.
+type planReview struct {
+	commands    []m.FileCommand
+	dropped     []bool
+	selectedIdx int
+	savedPath   string
+}
+
+// apply executes the commands, or collects them for review in the dry run mode.
+func (c *controller) apply(commands []m.FileCommand) {
+	if len(commands) == 0 {
+		return
+	}
+	if c.dryRun {
+		c.review = &planReview{commands: commands, dropped: make([]bool, len(commands))}
+		return
+	}
+	c.execute(commands)
+}
+
+// skipped tells which commands won't run: the dropped ones and the ones
+// touching the files of an earlier skipped command.
+func (r *planReview) skipped() []bool {
+	result := make([]bool, len(r.commands))
+	skippedIds := []m.Id{}
+	for i, cmd := range r.commands {
+		ids := touchedIds(cmd)
+		result[i] = r.dropped[i]
+		for _, id := range ids {
+			for _, skippedId := range skippedIds {
+				if overlaps(id, skippedId) {
+					result[i] = true
+				}
+			}
+		}
+		if result[i] {
+			skippedIds = append(skippedIds, ids...)
+		}
+	}
+	return result
+}
+
+func (r *planReview) kept() []m.FileCommand {
+	result := []m.FileCommand{}
+	for i, skipped := range r.skipped() {
+		if !skipped {
+			result = append(result, r.commands[i])
+		}
+	}
+	return result
+}
+
+func touchedIds(cmd m.FileCommand) []m.Id {
+	switch cmd := cmd.(type) {
+	case m.DeleteFile:
+		return []m.Id{cmd.Id}
+	case m.RenameFile:
+		return []m.Id{cmd.From, {Root: cmd.From.Root, Name: cmd.To}}
+	case m.RenameFolder:
+		return []m.Id{{Root: cmd.Root, Name: cmd.From.ParentName()}, {Root: cmd.Root, Name: cmd.To.ParentName()}}
+	case m.CopyFile:
+		return append([]m.Id{cmd.From}, cmd.To...)
+	}
+	return nil
+}
+
+func overlaps(a, b m.Id) bool {
+	if a.Root != b.Root {
+		return false
+	}
+	aPath, bPath := m.Path(a.Name.String()), m.Path(b.Name.String())
+	return isUnder(aPath, bPath) || isUnder(bPath, aPath)
+}
+
+func (c *controller) reviewView() *v.PlanView {
+	result := &v.PlanView{SelectedIdx: c.review.selectedIdx, SavedPath: c.review.savedPath}
+	copySizes := map[m.Root]uint64{}
+	for i, skipped := range c.review.skipped() {
+		cmd := c.review.commands[i]
+		operation := v.Operation{Text: commandReport(cmd).String(), Dropped: c.review.dropped[i], Skipped: skipped}
+		switch cmd := cmd.(type) {
+		case m.DeleteFile:
+			if file := c.file(cmd.Id); file != nil {
+				operation.Size = file.Size
+			}
+			if !skipped {
+				result.Deletes++
+				result.DeleteSize += operation.Size
+			}
+		case m.CopyFile:
+			if file := c.file(cmd.From); file != nil {
+				operation.Size = file.Size * uint64(len(cmd.To))
+				for _, to := range cmd.To {
+					if !skipped {
+						copySizes[to.Root] += file.Size
+					}
+				}
+			}
+		}
+		result.Operations = append(result.Operations, operation)
+	}
+	for _, root := range c.roots {
+		if size, ok := copySizes[root]; ok {
+			result.Copies = append(result.Copies, v.RootSize{Root: root, Size: size})
+		}
+	}
+	return result
+}
+
+func (c *controller) handleReviewEvent(event any) bool {
+	review := c.review
+	switch event := event.(type) {
+	case m.MoveSelection:
+		review.selectedIdx = clampIdx(review.selectedIdx+event.Lines, len(review.commands))
+
+	case m.Scroll:
+		review.selectedIdx = clampIdx(review.selectedIdx+event.Lines, len(review.commands))
+
+	case m.SelectFirst:
+		review.selectedIdx = 0
+
+	case m.SelectLast:
+		review.selectedIdx = clampIdx(len(review.commands)-1, len(review.commands))
+
+	case m.ToggleOperation, m.Delete:
+		review.dropped[review.selectedIdx] = !review.dropped[review.selectedIdx]
+
+	case m.Open, m.Enter:
+		commands := review.kept()
+		c.confirmation = &confirmation{
+			title:   "Apply Plan",
+			message: fmt.Sprintf("Apply %d of %d operations?", len(commands), len(review.commands)),
+			action: func() {
+				c.review = nil
+				c.execute(commands)
+			},
+		}
+
+	case m.SavePlan:
+		c.savePlan()
+
+	case m.Cancel, m.Exit:
+		c.review = nil
+
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+)
+
+func TestReview(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx", "a/y.txt": "yyyy"},
+		"copy 1": {"x.txt": "XXXX", "y.txt": "yyyy"},
+		"copy 2": {"x.txt": "xxxx", "a/y.txt": "yyyy"},
+	})
+	fs := c.shared.fs.(*testFs)
+	c.handleEvent(m.ToggleDryRun{})
+	c.handleEvent(m.ResolveAll{})
+	if c.review == nil || len(fs.commands) != 0 {
+		t.Fatalf("expected the plan to wait for review, got %v", fs.commands)
+	}
+	if plan := planString(c.review.commands); plan != "rename copy 1/x.txt -> x`1.txt\ncopy origin/x.txt -> copy 1/x.txt\nrename copy 1/y.txt -> a/y.txt" {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+
+	c.handleEvent(m.ToggleOperation{})
+	view := c.reviewView()
+	if !view.Operations[0].Dropped || !view.Operations[1].Skipped || view.Operations[2].Skipped || len(view.Copies) != 0 {
+		t.Errorf("expected the copy to depend on the dropped rename, got %+v", view)
+	}
+
+	c.handleEvent(m.Enter{})
+	c.handleEvent(m.Enter{})
+	if c.review != nil || c.confirmation != nil {
+		t.Errorf("expected the review to close after confirmation")
+	}
+	if plan := planString(fs.commands); plan != "rename copy 1/y.txt -> a/y.txt" {
+		t.Errorf("expected only the kept operation to run, got:\n%s", plan)
+	}
+}
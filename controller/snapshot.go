@@ -0,0 +1,92 @@
+package controller
+
+import (
+	m "arc/model"
+)
+
+// snapshot is the catalog as it was after the last successful sync.
+// The planner uses it as the common ancestor of all roots.
+type snapshot struct {
+	byName map[m.Name]m.Hash
+	// byHash holds every name of the content, as copies share a hash.
+	byHash map[m.Hash][]m.Name
+	sizes  map[uint64]bool
+}
+
+func newSnapshot(files []m.SnapshotFile) *snapshot {
+	s := &snapshot{
+		byName: map[m.Name]m.Hash{},
+		byHash: map[m.Hash][]m.Name{},
+		sizes:  map[uint64]bool{},
+	}
+	for _, file := range files {
+		s.byName[file.Name] = file.Hash
+		s.byHash[file.Hash] = append(s.byHash[file.Hash], file.Name)
+		s.sizes[file.Size] = true
+	}
+	return s
+}
+
+func (s *snapshot) isAncestor(file *m.File) bool {
+	return s != nil && file.Hash != "" && s.byName[file.Name] == file.Hash
+}
+
+func (c *controller) snapshotLoaded(event m.SnapshotLoaded) {
+	c.snapshot = newSnapshot(event.Files)
+	for _, file := range event.Accepted {
+		c.accepted[file.Hash] = append(c.accepted[file.Hash], file.Id)
+	}
+	c.interrupted = event.Interrupted
+	c.journal = append(event.Journal, c.journal...)
+	c.analyzeIfReady()
+}
+
+func (c *controller) analyzeIfReady() {
+	if c.snapshot == nil {
+		return
+	}
+	for _, archive := range c.archives {
+		if archive.state != ready {
+			return
+		}
+	}
+	c.requestHashes()
+	if c.hashing > 0 {
+		return
+	}
+	c.analyzed = true
+	c.analyzeDiscrepancies()
+	c.updateConflicts()
+	c.storeSnapshotIfSynced()
+	c.offerResume()
+}
+
+// storeSnapshotIfSynced records the catalog once every root holds the same files.
+func (c *controller) storeSnapshotIfSynced() {
+	if c.snapshot == nil {
+		return
+	}
+	files := []m.SnapshotFile{}
+	for _, archive := range c.archives {
+		if archive.state != ready {
+			return
+		}
+		for _, folder := range archive.folders {
+			for _, file := range folder.files {
+				if file.State != m.Resolved && file.State != m.Hashed {
+					return
+				}
+				if archive.root == c.roots[0] {
+					files = append(files, m.SnapshotFile{
+						Name:    file.Name,
+						Hash:    file.Hash,
+						Size:    file.Size,
+						ModTime: file.ModTime,
+					})
+				}
+			}
+		}
+	}
+	c.snapshot = newSnapshot(files)
+	c.shared.fs.Send(m.StoreSnapshot{Roots: c.roots, Files: files})
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	m "arc/model"
+	v "arc/view"
+	"fmt"
+	"time"
+)
+
+func (c *controller) showTrash() {
+	c.screen = trashScreen
+	c.shared.fs.Send(m.ListTrash{Roots: c.roots})
+}
+
+func (c *controller) trashView() *v.TrashView {
+	return &v.TrashView{Files: c.trash, SelectedIdx: c.trashIdx}
+}
+
+func (c *controller) trashListed(event m.TrashListed) {
+	c.trash = event.Files
+	c.trashIdx = clampIdx(c.trashIdx, len(c.trash))
+}
+
+// fileRestored puts the restored file back into the catalog.
+func (c *controller) fileRestored(event m.FileRestored) {
+	restored := event.File
+	archive, ok := c.archives[restored.Root]
+	if !ok || c.file(restored.Id) != nil {
+		return
+	}
+	file := &m.File{
+		Meta:  m.Meta{Id: restored.Id, Size: restored.Size, ModTime: restored.ModTime.UTC().Round(time.Second)},
+		Hash:  restored.Hash,
+		State: m.Hashed,
+	}
+	archive.getFolder(file.Path).files[file.Base] = file
+	c.byHash[file.Hash] = append(c.byHash[file.Hash], file)
+	c.analyzeDiscrepancy(file.Hash)
+	c.updateConflicts()
+}
+
+func (c *controller) handleTrashEvent(event any) bool {
+	switch event := event.(type) {
+	case m.MoveSelection:
+		c.trashIdx = clampIdx(c.trashIdx+event.Lines, len(c.trash))
+
+	case m.Scroll:
+		c.trashIdx = clampIdx(c.trashIdx+event.Lines, len(c.trash))
+
+	case m.SelectFirst:
+		c.trashIdx = 0
+
+	case m.SelectLast:
+		c.trashIdx = clampIdx(len(c.trash)-1, len(c.trash))
+
+	case m.Open, m.Enter:
+		if c.trashIdx < len(c.trash) {
+			file := c.trash[c.trashIdx]
+			c.confirmation = &confirmation{
+				title:   "Restore",
+				message: fmt.Sprintf("Restore %q?", file.Id),
+				action:  func() { c.shared.fs.Send(m.RestoreFile{File: file}) },
+			}
+		}
+
+	case m.Delete:
+		if c.trashIdx < len(c.trash) {
+			file := c.trash[c.trashIdx]
+			c.confirmation = &confirmation{
+				title:   "Purge",
+				message: fmt.Sprintf("Purge the files deleted on %s and before?", file.Deleted.Local().Format(time.DateTime)),
+				action:  func() { c.shared.fs.Send(m.PurgeTrash{Roots: c.roots, Before: file.Deleted.Add(time.Second)}) },
+			}
+		}
+
+	case m.Cancel, m.Exit, m.ShowTrash:
+		c.screen = folderScreen
+
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	m "arc/model"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	c := newTestController(map[m.Root]map[string]m.Hash{
+		"origin": {"x.txt": "xxxx"},
+		"copy 1": {"x.txt": "xxxx", "y.txt": "yyyy"},
+		"copy 2": {"x.txt": "xxxx", "y.txt": "yyyy"},
+	})
+	deleted := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trashed := m.TrashedFile{Id: testId("origin", "y.txt"), Batch: "20240101-000000", Hash: "yyyy", Deleted: deleted}
+
+	c.handleEvent(m.ShowTrash{})
+	c.handleEvent(m.TrashListed{Files: []m.TrashedFile{trashed}})
+	c.handleEvent(m.Enter{})
+	c.handleEvent(m.Enter{})
+	fs := c.shared.fs.(*testFs)
+	if len(fs.commands) != 2 || fs.commands[1] != (m.RestoreFile{File: trashed}) {
+		t.Fatalf("expected to restore y.txt, got %v", fs.commands)
+	}
+
+	if file := c.file(testId("copy 1", "y.txt")); file.State != m.Divergent {
+		t.Errorf("expected y.txt in copy 1 to diverge before restore, got %v", file.State)
+	}
+	c.handleEvent(m.FileRestored{File: trashed})
+	for _, root := range testRoots {
+		if file := c.file(testId(root, "y.txt")); file == nil || file.State == m.Divergent {
+			t.Errorf("expected y.txt in %s to be restored and in sync, got %v", root, file)
+		}
+	}
+
+	c.handleEvent(m.Delete{})
+	c.handleEvent(m.Enter{})
+	purge, ok := fs.commands[2].(m.PurgeTrash)
+	if !ok || !purge.Before.After(deleted) {
+		t.Errorf("expected to purge the trash up to the selected file, got %v", fs.commands[2])
+	}
+}
@@ -0,0 +1,38 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"crypto/sha256"
+	"encoding/base64"
+	"path/filepath"
+	"syscall"
+)
+
+const catalogDirName = "catalog"
+
+// catalogPaths are the hash stores of the root in the catalog directory,
+// the current one first. An initialized root is known by its identity,
+// others by the file system and the path of the root within it, so the
+// root keeps its store wherever the file system is mounted.
+func catalogPaths(root m.Root) []string {
+	keys := []string{}
+	if key := rootKey(root); key != root.String() {
+		keys = append(keys, key)
+	}
+	if uuid, path, ok := fileSystemOf(root.String()); ok {
+		keys = append(keys, uuid+":"+path)
+	} else {
+		keys = append(keys, root.String())
+	}
+	paths := make([]string, len(keys))
+	for i, key := range keys {
+		sum := sha256.Sum256([]byte(key))
+		paths[i] = filepath.Join(DataDir(), catalogDirName, base64.RawURLEncoding.EncodeToString(sum[:])+".csv")
+	}
+	return paths
+}
+
+// isWritable tells if files can be created in the root.
+func isWritable(root m.Root) bool {
+	return syscall.Access(root.String(), 2) == nil
+}
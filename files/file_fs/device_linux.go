@@ -0,0 +1,33 @@
+package file_fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// isRotational tells if the device is a spinning disk; unknown devices are.
+func isRotational(dev uint64) bool {
+	major := ((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)
+	minor := (dev & 0xff) | ((dev >> 12) &^ 0xff)
+	path, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return true
+	}
+	// Partitions have the queue of their disk.
+	for _, dir := range []string{path, filepath.Dir(path)} {
+		rotational, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+		if err == nil {
+			return strings.TrimSpace(string(rotational)) != "0"
+		}
+	}
+	return true
+}
+
+// changeTime is when the inode last changed; a reused inode has a new one.
+func changeTime(sys *syscall.Stat_t) time.Time {
+	return time.Unix(sys.Ctim.Unix()).UTC()
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package file_fs
+
+import (
+	"syscall"
+	"time"
+)
+
+// isRotational tells if the device is a spinning disk; without a way to
+// tell, every device is treated as one.
+func isRotational(dev uint64) bool {
+	return true
+}
+
+// changeTime is unknown here, so every inode looks unchanged.
+func changeTime(sys *syscall.Stat_t) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,44 @@
+package file_fs
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const fsIocFiemap = 0xC020660B
+
+type fiemapExtent struct {
+	logical    uint64
+	physical   uint64
+	length     uint64
+	reserved64 [2]uint64
+	flags      uint32
+	reserved   [3]uint32
+}
+
+type fiemap struct {
+	start         uint64
+	length        uint64
+	flags         uint32
+	mappedExtents uint32
+	extentCount   uint32
+	reserved      uint32
+	extents       [1]fiemapExtent
+}
+
+// physicalOffset is where the first extent of the file starts on the disk.
+func physicalOffset(path string) (uint64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	request := fiemap{length: ^uint64(0), extentCount: 1}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), fsIocFiemap, uintptr(unsafe.Pointer(&request)))
+	if errno != 0 || request.mappedExtents == 0 {
+		return 0, false
+	}
+	return request.extents[0].physical, true
+}
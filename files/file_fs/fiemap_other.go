@@ -0,0 +1,8 @@
+//go:build !linux
+
+package file_fs
+
+// physicalOffset reports nothing where FIEMAP is not available.
+func physicalOffset(path string) (uint64, bool) {
+	return 0, false
+}
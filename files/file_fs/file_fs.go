@@ -6,21 +6,65 @@ import (
 	"arc/stream"
 	"os"
 	"path/filepath"
+	"time"
 
 	"golang.org/x/text/unicode/norm"
 )
 
 type fileFs struct {
-	events   *stream.Stream[m.Event]
-	lc       *lifecycle.Lifecycle
-	commands *stream.Stream[m.FileCommand]
+	events    *stream.Stream[m.Event]
+	lc        *lifecycle.Lifecycle
+	commands  *stream.Stream[m.FileCommand]
+	retention m.Retention
+	devices   *devices
+	hashOrder HashOrder
+	lazy      bool
+	algorithm HashAlgorithm
+	stores    *hashStores
+	session   string
+	journaled int
 }
 
-func NewFs(events *stream.Stream[m.Event], lc *lifecycle.Lifecycle) m.FS {
+type Options struct {
+	Retention m.Retention
+
+	// HDDWorkers and SSDWorkers are how many files are hashed at once per disk.
+	HDDWorkers int
+	SSDWorkers int
+
+	HashOrder HashOrder
+
+	// Lazy scans report sizes and stored hashes only.
+	Lazy bool
+
+	// HashAlgorithm defaults to SHA256.
+	HashAlgorithm HashAlgorithm
+
+	// Catalog are the roots whose hash stores are kept in the catalog
+	// directory instead of the root; read-only roots always are.
+	Catalog []m.Root
+}
+
+func NewFs(events *stream.Stream[m.Event], lc *lifecycle.Lifecycle, options Options) m.FS {
 	fs := &fileFs{
-		events:   events,
-		lc:       lc,
-		commands: stream.NewStream[m.FileCommand]("file-fs"),
+		events:    events,
+		lc:        lc,
+		commands:  stream.NewStream[m.FileCommand]("file-fs"),
+		retention: options.Retention,
+		hashOrder: options.HashOrder,
+		lazy:      options.Lazy,
+		algorithm: options.HashAlgorithm,
+		devices: &devices{
+			hddWorkers: options.HDDWorkers,
+			ssdWorkers: options.SSDWorkers,
+			byDev:      map[uint64]*device{},
+		},
+		stores:  &hashStores{byRoot: map[m.Root]*hashStore{}, catalog: options.Catalog},
+		session: time.Now().UTC().Format(batchLayout),
+	}
+
+	if fs.algorithm == nil {
+		fs.algorithm = SHA256
 	}
 
 	go fs.handleEvents()
@@ -29,17 +73,34 @@ func NewFs(events *stream.Stream[m.Event], lc *lifecycle.Lifecycle) m.FS {
 }
 
 func (fs *fileFs) Scan(root m.Root) {
+	if identity, err := ReadIdentity(root); err == nil {
+		fs.events.Push(m.ArchiveIdentified{Root: root, Identity: identity})
+	} else if !os.IsNotExist(err) {
+		fs.events.Push(m.Error{Error: err})
+	}
+	store, err := fs.stores.store(root)
+	if err != nil {
+		fs.events.Push(m.Error{Error: err})
+	}
 	s := &scanner{
-		root:   root,
-		events: fs.events,
-		lc:     fs.lc,
-		metas:  map[uint64]*m.Meta{},
-		hashes: map[uint64]m.Hash{},
+		root:      root,
+		events:    fs.events,
+		lc:        fs.lc,
+		device:    fs.devices.device(root.String()),
+		order:     fs.hashOrder,
+		lazy:      fs.lazy,
+		algorithm: fs.algorithm,
+		store:     store,
+		metas:     map[uint64]*m.Meta{},
+		files:     map[uint64]hashEntry{},
+		hashes:    map[uint64]m.Hash{},
 	}
 	go s.scanArchive()
 }
 
+// Send queues the command; stopping the lifecycle waits for the queued commands.
 func (fs *fileFs) Send(cmd m.FileCommand) {
+	fs.lc.Started()
 	fs.commands.Push(cmd)
 }
 
@@ -53,7 +114,6 @@ func (fs *fileFs) handleEvents() {
 }
 
 func (fs *fileFs) handleCommand(cmd m.FileCommand) {
-	fs.lc.Started()
 	defer fs.lc.Done()
 
 	switch cmd := cmd.(type) {
@@ -63,8 +123,35 @@ func (fs *fileFs) handleCommand(cmd m.FileCommand) {
 	case m.RenameFile:
 		fs.renameFile(cmd)
 
+	case m.RenameFolder:
+		fs.renameFolder(cmd)
+
 	case m.CopyFile:
 		fs.copyFile(cmd)
+
+	case m.StoreSnapshot:
+		fs.storeSnapshot(cmd)
+
+	case m.StoreAccepted:
+		fs.storeAccepted(cmd)
+
+	case m.StorePlan:
+		fs.storePlan(cmd)
+
+	case m.ListTrash:
+		fs.listTrash(cmd.Roots)
+
+	case m.RestoreFile:
+		fs.restoreFile(cmd)
+
+	case m.PurgeTrash:
+		fs.purgeTrash(cmd)
+
+	case m.MarkUndone:
+		fs.markUndone(cmd)
+
+	case m.HashFiles:
+		fs.hashFiles(cmd)
 	}
 }
 
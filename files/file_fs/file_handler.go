@@ -2,38 +2,41 @@ package file_fs
 
 import (
 	m "arc/model"
+	"bytes"
 	"io"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
 func (f *fileFs) deleteFile(delete m.DeleteFile) {
 	log.Printf("### delete %q", delete.Id)
+	trashed, err := f.trash(delete)
+	if err != nil {
+		f.events.Push(m.Error{Id: delete.Id, Error: err})
+		failed := m.DeleteFailed{Meta: m.Meta{Id: delete.Id}, Hash: delete.Hash}
+		if info, err := os.Stat(delete.Id.String()); err == nil {
+			failed.Size, failed.ModTime, failed.Kept = uint64(info.Size()), info.ModTime(), true
+		}
+		f.events.Push(failed)
+		return
+	}
 	defer func() {
 		f.events.Push(m.FileDeleted(delete))
 	}()
-	err := os.Remove(delete.Id.String())
+	entry := m.JournalEntry{Operation: m.NewOperation(delete, nil), Trash: trashed.Batch}
+	entry.Size, entry.ModTime = trashed.Size, trashed.ModTime
+	f.record(entry)
+	// Remove fails unless the folder is empty, which leaves every other file alone.
+	if delete.Id.Path != "" {
+		os.Remove(filepath.Join(delete.Id.Root.String(), delete.Id.Path.String()))
+	}
+	purged, err := EnforceRetention(delete.Id.Root, f.retention, f.session)
+	f.recordPurged(purged)
 	if err != nil {
 		f.events.Push(m.Error{Id: delete.Id, Error: err})
 	}
-	path := filepath.Join(delete.Id.Root.String(), delete.Id.Path.String())
-	fsys := os.DirFS(path)
-
-	entries, _ := fs.ReadDir(fsys, ".")
-	hasFiles := false
-	for _, entry := range entries {
-		if entry.Name() != ".DS_Store" && !strings.HasPrefix(entry.Name(), "._") {
-			hasFiles = true
-			break
-		}
-	}
-	if !hasFiles {
-		os.RemoveAll(path)
-	}
 }
 
 func (f *fileFs) renameFile(rename m.RenameFile) {
@@ -46,10 +49,37 @@ func (f *fileFs) renameFile(rename m.RenameFile) {
 	if err != nil {
 		f.events.Push(m.Error{Id: rename.From, Error: err})
 	}
-	err = os.Rename(rename.From.String(), rename.To.String())
+	to := m.Id{Root: rename.From.Root, Name: rename.To}
+	err = os.Rename(rename.From.String(), to.String())
 	if err != nil {
 		f.events.Push(m.Error{Id: rename.From, Error: err})
+		return
+	}
+	entry := m.JournalEntry{Operation: m.NewOperation(rename, nil)}
+	if info, err := os.Stat(to.String()); err == nil {
+		entry.Size, entry.ModTime = uint64(info.Size()), info.ModTime()
+		f.renamed(rename.From, newHashEntry(to, info, ""))
+	}
+	f.record(entry)
+}
+
+func (f *fileFs) renameFolder(rename m.RenameFolder) {
+	log.Printf("### rename folder %q to %q", rename.From, rename.To)
+	defer func() {
+		f.events.Push(m.FolderRenamed(rename))
+	}()
+	id := m.Id{Root: rename.Root, Name: rename.From.ParentName()}
+	path := filepath.Join(rename.Root.String(), rename.To.ParentName().Path.String())
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		f.events.Push(m.Error{Id: id, Error: err})
+	}
+	err = os.Rename(filepath.Join(rename.Root.String(), rename.From.String()), filepath.Join(rename.Root.String(), rename.To.String()))
+	if err != nil {
+		f.events.Push(m.Error{Id: id, Error: err})
+		return
 	}
+	f.record(m.JournalEntry{Operation: m.NewOperation(rename, nil)})
 }
 
 func (f *fileFs) copyFile(copy m.CopyFile) {
@@ -65,12 +95,16 @@ func (f *fileFs) copyFile(copy m.CopyFile) {
 	events := make([]chan event, len(copy.To))
 	copied := make([]uint64, len(copy.To))
 	reported := uint64(0)
+	done := []hashEntry{}
+	offsets := make([]uint64, len(copy.To))
 
-	for i := range copy.To {
+	for i, to := range copy.To {
 		events[i] = make(chan event, 1)
+		offsets[i] = resumeOffset(copy, to)
+		f.checkpoint(copy, to, offsets[i])
 	}
 
-	go f.reader(copy.From, copy.To, events)
+	go f.reader(copy.From, copy.Hash, copy.To, offsets, events)
 
 	for {
 		hasValue := false
@@ -85,6 +119,14 @@ func (f *fileFs) copyFile(copy m.CopyFile) {
 
 				case copyError:
 					f.events.Push(m.Error{Id: event.Id, Error: event.Error})
+
+				case copyCheckpoint:
+					copied[i] = uint64(event)
+					minCopied = copied[i]
+					f.checkpoint(copy, copy.To[i], uint64(event))
+
+				case copyDone:
+					done = append(done, hashEntry(event))
 				}
 			}
 		}
@@ -101,6 +143,30 @@ func (f *fileFs) copyFile(copy m.CopyFile) {
 			break
 		}
 	}
+
+	// Interrupted copies keep their state to resume from on the next run.
+	if !f.lc.ShoudStop() {
+		for _, to := range copy.To {
+			os.Remove(copyStatePath(copy.From, to))
+		}
+	}
+
+	// Seed the hash stores, so the next scan doesn't read the copies again.
+	for _, entry := range done {
+		if err := f.addHashes(entry.id.Root, entry); err != nil {
+			f.events.Push(m.Error{Id: entry.id, Error: err})
+		}
+	}
+
+	if len(done) > 0 {
+		committed := m.CopyFile{Hash: copy.Hash, From: copy.From}
+		for _, entry := range done {
+			committed.To = append(committed.To, entry.id)
+		}
+		entry := m.JournalEntry{Operation: m.NewOperation(committed, nil)}
+		entry.Size, entry.ModTime = done[0].size, done[0].modTime
+		f.record(entry)
+	}
 }
 
 type event interface {
@@ -115,10 +181,48 @@ type copyError m.Error
 
 func (copyError) event() {}
 
-func (f *fileFs) reader(source m.Id, targets []m.Id, eventChans []chan event) {
-	commands := make([]chan []byte, len(targets))
+// copyCheckpoint is a copyProgress with all the bytes so far synced to disk.
+type copyCheckpoint uint64
+
+func (copyCheckpoint) event() {}
+
+// copyDone is a copy committed under its final name.
+type copyDone hashEntry
+
+func (copyDone) event() {}
+
+// chunk is the next part of the source; the last chunk carries the hash
+// of everything read instead of data.
+type chunk struct {
+	data []byte
+	hash m.Hash
+}
+
+// copyTempPath is where a copy is written before it is committed; scans skip
+// the hidden file, so an interrupted copy is never taken for content.
+func copyTempPath(id m.Id) string {
+	return filepath.Join(id.Root.String(), id.Path.String(), "."+id.Base.String()+".arc-copy")
+}
+
+func (f *fileFs) checkpoint(copy m.CopyFile, to m.Id, committed uint64) {
+	if copy.Hash == "" {
+		return
+	}
+	err := writeCopyState(m.InterruptedCopy{From: copy.From, To: to, Hash: copy.Hash, Committed: committed})
+	if err != nil {
+		f.events.Push(m.Error{Id: to, Error: err})
+	}
+}
+
+func (f *fileFs) reader(source m.Id, hash m.Hash, targets []m.Id, offsets []uint64, eventChans []chan event) {
+	commands := make([]chan chunk, len(targets))
 	defer func() {
-		for _, cmdChan := range commands {
+		for i, cmdChan := range commands {
+			if cmdChan == nil {
+				// No writer was started to close its events.
+				close(eventChans[i])
+				continue
+			}
 			close(cmdChan)
 		}
 	}()
@@ -130,8 +234,8 @@ func (f *fileFs) reader(source m.Id, targets []m.Id, eventChans []chan event) {
 	}
 
 	for i := range targets {
-		commands[i] = make(chan []byte)
-		go f.writer(m.Id{Root: targets[i].Root, Name: source.Name}, info.ModTime(), commands[i], eventChans[i])
+		commands[i] = make(chan chunk)
+		go f.writer(targets[i], hash, info.ModTime(), offsets[i], commands[i], eventChans[i])
 	}
 
 	sourceFile, err := os.Open(source.String())
@@ -139,54 +243,160 @@ func (f *fileFs) reader(source m.Id, targets []m.Id, eventChans []chan event) {
 		f.events.Push(m.Error{Id: source, Error: err})
 		return
 	}
+	defer sourceFile.Close()
 
+	// The source is hashed by the algorithm of the expected hash.
+	algorithm := f.algorithm
+	if known := algorithmOf(hash); hash != "" && known != nil {
+		algorithm = known
+	}
+	sourceHash := algorithm.New()
 	var n int
-	for err != io.EOF && !f.lc.ShoudStop() {
+	for err != io.EOF {
+		if f.lc.ShoudStop() {
+			return
+		}
 		buf := make([]byte, 1024*1024)
 		n, err = sourceFile.Read(buf)
 		if err != nil && err != io.EOF {
 			f.events.Push(m.Error{Id: source, Error: err})
 			return
 		}
+		sourceHash.Write(buf[:n])
 		for _, cmd := range commands {
-			cmd <- buf[:n]
+			cmd <- chunk{data: buf[:n]}
 		}
 	}
+	read := encodeHash(algorithm, sourceHash.Sum(nil))
+	for _, cmd := range commands {
+		cmd <- chunk{hash: read}
+	}
 }
 
-func (f *fileFs) writer(id m.Id, modTime time.Time, cmdChan chan []byte, eventChan chan event) {
-	var copied copyProgress
+// writer commits the copy only after the reader confirms that the bytes it
+// streamed have the expected hash and the synced file reads back with it.
+// When resuming, it checks the partial file against the streamed bytes up to
+// the offset instead of writing them again.
+func (f *fileFs) writer(id m.Id, hash m.Hash, modTime time.Time, offset uint64, cmdChan chan chunk, eventChan chan event) {
+	defer close(eventChan)
 
-	filePath := filepath.Join(id.Root.String(), id.Path.String())
-	os.MkdirAll(filePath, 0755)
-	file, err := os.Create(id.String())
+	path := filepath.Join(id.Root.String(), id.Path.String())
+	os.MkdirAll(path, 0755)
+	tmpPath := copyTempPath(id)
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err == nil {
+		var info os.FileInfo
+		info, err = file.Stat()
+		if err == nil && uint64(info.Size()) < offset {
+			offset = uint64(info.Size())
+		}
+	}
+	if err == nil {
+		err = file.Truncate(int64(offset))
+	}
 	if err != nil {
 		f.events.Push(m.Error{Id: id, Error: err})
+		if file != nil {
+			file.Close()
+		}
+		for range cmdChan {
+		}
 		return
 	}
-
+	committed := false
 	defer func() {
 		if file != nil {
 			file.Close()
-			if f.lc.ShoudStop() {
-				os.Remove(filePath)
-			}
-			os.Chtimes(id.String(), time.Now(), modTime)
 		}
-		close(eventChan)
+		if !committed && !f.lc.ShoudStop() {
+			os.Remove(tmpPath)
+		}
+		// Keep the reader going for the other targets.
+		for range cmdChan {
+		}
 	}()
 
+	var pos, checkpoint uint64
+	var read m.Hash
 	for cmd := range cmdChan {
 		if f.lc.ShoudStop() {
 			return
 		}
+		if cmd.hash != "" {
+			read = cmd.hash
+			continue
+		}
+
+		data := cmd.data
+		if pos < offset {
+			verified := uint64(len(data))
+			if verified > offset-pos {
+				verified = offset - pos
+			}
+			existing := make([]byte, verified)
+			_, err := file.ReadAt(existing, int64(pos))
+			if err == nil && bytes.Equal(existing, data[:verified]) {
+				pos += verified
+				data = data[verified:]
+			} else {
+				offset = pos
+				err = file.Truncate(int64(pos))
+				if err != nil {
+					f.events.Push(m.Error{Id: id, Error: err})
+					return
+				}
+			}
+		}
+		if len(data) > 0 {
+			n, err := file.WriteAt(data, int64(pos))
+			pos += uint64(n)
+			if err != nil {
+				f.events.Push(m.Error{Id: id, Error: err})
+				return
+			}
+		}
+		if pos-checkpoint >= checkpointSize && pos > offset && file.Sync() == nil {
+			checkpoint = pos
+			eventChan <- copyCheckpoint(pos)
+		} else {
+			eventChan <- copyProgress(pos)
+		}
+	}
+	if read == "" || f.lc.ShoudStop() {
+		return
+	}
+	if hash != "" && read != hash {
+		f.events.Push(m.HashMismatch{Id: id, Expected: hash, Actual: read})
+		return
+	}
 
-		n, err := file.Write([]byte(cmd))
-		copied += copyProgress(n)
-		if err != nil {
-			f.events.Push(m.Error{Id: id, Error: err})
+	err = file.Sync()
+	if err == nil {
+		err = file.Close()
+	}
+	file = nil
+	if algorithm := algorithmOf(read); err == nil && algorithm != nil {
+		var written m.Hash
+		written, err = hashPath(tmpPath, algorithm)
+		if err == nil && written != read {
+			f.events.Push(m.HashMismatch{Id: id, Expected: read, Actual: written})
 			return
 		}
-		eventChan <- copied
 	}
+	if err == nil {
+		err = os.Chtimes(tmpPath, time.Now(), modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, id.String())
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = os.Stat(id.String())
+	}
+	if err != nil {
+		f.events.Push(m.Error{Id: id, Error: err})
+		return
+	}
+	committed = true
+	eventChan <- copyDone(newHashEntry(id, info, read))
 }
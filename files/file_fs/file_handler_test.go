@@ -0,0 +1,142 @@
+package file_fs
+
+import (
+	"arc/lifecycle"
+	m "arc/model"
+	"arc/stream"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestFs(t *testing.T) *fileFs {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	return &fileFs{
+		events:    stream.NewStream[m.Event]("test"),
+		lc:        lifecycle.New(),
+		algorithm: SHA256,
+		stores:    &hashStores{byRoot: map[m.Root]*hashStore{}},
+		session:   time.Now().UTC().Format(batchLayout),
+	}
+}
+
+// runCopy copies the file and returns the events, failing on a copy that never finishes.
+func runCopy(t *testing.T, f *fileFs, copy m.CopyFile) []m.Event {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.copyFile(copy)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the copy did not finish")
+	}
+	events, _ := f.events.TryPull()
+	return events
+}
+
+func TestCopyMissingSource(t *testing.T) {
+	f := newTestFs(t)
+	origin, copy := m.Root(t.TempDir()), m.Root(t.TempDir())
+	from := m.Id{Root: origin, Name: m.Path("missing.txt").ParentName()}
+	to := m.Id{Root: copy, Name: m.Path("missing.txt").ParentName()}
+
+	events := runCopy(t, f, m.CopyFile{Hash: contentHash("a"), From: from, To: []m.Id{to}})
+	failed, copied := false, false
+	for _, event := range events {
+		switch event := event.(type) {
+		case m.Error:
+			failed = failed || event.Id == from
+		case m.FileCopied:
+			copied = true
+		case m.Journaled:
+			t.Errorf("expected nothing to be journaled, got %v", event)
+		}
+	}
+	if !failed || !copied {
+		t.Errorf("expected an error and the end of the copy, got %v", events)
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	for _, resumed := range []string{"", "first ", "wrong "} {
+		t.Run(fmt.Sprintf("resumed %q", resumed), func(t *testing.T) {
+			f := newTestFs(t)
+			origin, copy := m.Root(t.TempDir()), m.Root(t.TempDir())
+			content := "first half, second half"
+			from := testFile(t, origin, "a.txt", content).id
+			to := m.Id{Root: copy, Name: from.Name}
+			if resumed != "" {
+				tmpPath := copyTempPath(to)
+				if err := os.WriteFile(tmpPath, []byte(resumed), 0644); err != nil {
+					t.Fatal(err)
+				}
+				state := m.InterruptedCopy{From: from, To: to, Hash: contentHash(content), Committed: uint64(len(resumed))}
+				if err := writeCopyState(state); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			events := runCopy(t, f, m.CopyFile{Hash: contentHash(content), From: from, To: []m.Id{to}})
+			for _, event := range events {
+				if _, ok := event.(m.Error); ok {
+					t.Errorf("unexpected %v", event)
+				}
+			}
+			if data, err := os.ReadFile(to.String()); err != nil || string(data) != content {
+				t.Errorf("expected %q, got %q, %v", content, data, err)
+			}
+			if _, err := os.Stat(copyStatePath(from, to)); !os.IsNotExist(err) {
+				t.Errorf("expected the copy state to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCopyHashMismatch(t *testing.T) {
+	f := newTestFs(t)
+	origin, copy := m.Root(t.TempDir()), m.Root(t.TempDir())
+	from := testFile(t, origin, "a.txt", "changed").id
+	to := m.Id{Root: copy, Name: from.Name}
+
+	events := runCopy(t, f, m.CopyFile{Hash: contentHash("planned"), From: from, To: []m.Id{to}})
+	if !slices.ContainsFunc(events, func(event m.Event) bool { _, ok := event.(m.HashMismatch); return ok }) {
+		t.Errorf("expected a hash mismatch, got %v", events)
+	}
+	entries, _ := os.ReadDir(copy.String())
+	if len(entries) != 0 {
+		t.Errorf("expected nothing to be left in the copy, got %v", entries)
+	}
+}
+
+func TestDeleteFailed(t *testing.T) {
+	f := newTestFs(t)
+	root := m.Root(t.TempDir())
+	file := testFile(t, root, "a.txt", "aaaa")
+	// A file in the way of the trash folder makes the trash fail.
+	if err := os.WriteFile(filepath.Join(root.String(), trashDirName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f.deleteFile(m.DeleteFile{Id: file.id, Hash: contentHash("aaaa")})
+	events, _ := f.events.TryPull()
+	for _, event := range events {
+		switch event := event.(type) {
+		case m.FileDeleted, m.Journaled:
+			t.Errorf("unexpected %v", event)
+		case m.DeleteFailed:
+			if !event.Kept || event.Id != file.id || event.Size != 4 {
+				t.Errorf("expected a.txt to be kept, got %v", event)
+			}
+		}
+	}
+	if !slices.ContainsFunc(events, func(event m.Event) bool { _, ok := event.(m.DeleteFailed); return ok }) {
+		t.Errorf("expected the delete to fail, got %v", events)
+	}
+}
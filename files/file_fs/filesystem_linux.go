@@ -0,0 +1,41 @@
+package file_fs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileSystemOf returns the UUID of the file system holding the path and
+// the path relative to where the file system is mounted.
+func fileSystemOf(path string) (uuid, rel string, ok bool) {
+	var stat syscall.Stat_t
+	if syscall.Stat(path, &stat) != nil {
+		return "", "", false
+	}
+	mount := path
+	for mount != "/" {
+		var parent syscall.Stat_t
+		if syscall.Stat(filepath.Dir(mount), &parent) != nil || parent.Dev != stat.Dev {
+			break
+		}
+		mount = filepath.Dir(mount)
+	}
+	rel, err := filepath.Rel(mount, path)
+	if err != nil {
+		return "", "", false
+	}
+
+	const byUUID = "/dev/disk/by-uuid"
+	entries, err := os.ReadDir(byUUID)
+	if err != nil {
+		return "", "", false
+	}
+	for _, entry := range entries {
+		var device syscall.Stat_t
+		if syscall.Stat(filepath.Join(byUUID, entry.Name()), &device) == nil && uint64(device.Rdev) == uint64(stat.Dev) {
+			return entry.Name(), rel, true
+		}
+	}
+	return "", "", false
+}
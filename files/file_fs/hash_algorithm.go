@@ -0,0 +1,110 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// HashAlgorithm computes the hashes files are compared by.
+type HashAlgorithm interface {
+	// Name is recorded with every hash the algorithm makes.
+	Name() string
+	New() hash.Hash
+}
+
+var (
+	SHA256  HashAlgorithm = sha256Algorithm{}
+	DualCRC HashAlgorithm = dualCRCAlgorithm{}
+)
+
+// HashAlgorithms are the algorithms hashes can be made and read with; SHA256 is the default.
+var HashAlgorithms = []HashAlgorithm{SHA256, DualCRC}
+
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	for _, algorithm := range HashAlgorithms {
+		if algorithm.Name() == name {
+			return algorithm, nil
+		}
+	}
+	return SHA256, fmt.Errorf("unknown hash algorithm %q", name)
+}
+
+// encodeHash tags the sum with the algorithm, so hashes made by different
+// algorithms never compare equal. SHA-256 hashes stay untagged as they were
+// before the algorithm could be chosen.
+func encodeHash(algorithm HashAlgorithm, sum []byte) m.Hash {
+	hash := base64.RawURLEncoding.EncodeToString(sum)
+	if algorithm == SHA256 {
+		return m.Hash(hash)
+	}
+	return m.Hash(algorithm.Name() + ":" + hash)
+}
+
+// algorithmOf returns the algorithm that made the hash, or nil for an unknown one.
+func algorithmOf(hash m.Hash) HashAlgorithm {
+	name, _, tagged := strings.Cut(hash.String(), ":")
+	if !tagged {
+		return SHA256
+	}
+	for _, algorithm := range HashAlgorithms {
+		if algorithm.Name() == name {
+			return algorithm
+		}
+	}
+	return nil
+}
+
+// algorithmName names the algorithm from the tag of the hash, even for algorithms this build doesn't know.
+func algorithmName(hash m.Hash) string {
+	name, _, tagged := strings.Cut(hash.String(), ":")
+	if !tagged {
+		return SHA256.Name()
+	}
+	return name
+}
+
+type sha256Algorithm struct{}
+
+func (sha256Algorithm) Name() string   { return "sha256" }
+func (sha256Algorithm) New() hash.Hash { return sha256.New() }
+
+// dualCRCAlgorithm joins CRC-32C and CRC-32 into 64 bits. Both run in
+// hardware, which makes it many times faster than SHA-256; it tells files
+// apart for bulk comparison but is no defense against deliberate collisions.
+type dualCRCAlgorithm struct{}
+
+func (dualCRCAlgorithm) Name() string { return "dualcrc" }
+
+func (dualCRCAlgorithm) New() hash.Hash {
+	return &dualCRC{
+		castagnoli: crc32.New(crc32.MakeTable(crc32.Castagnoli)),
+		ieee:       crc32.NewIEEE(),
+	}
+}
+
+type dualCRC struct {
+	castagnoli hash.Hash32
+	ieee       hash.Hash32
+}
+
+func (d *dualCRC) Write(p []byte) (int, error) {
+	d.castagnoli.Write(p)
+	return d.ieee.Write(p)
+}
+
+func (d *dualCRC) Sum(b []byte) []byte {
+	return d.ieee.Sum(d.castagnoli.Sum(b))
+}
+
+func (d *dualCRC) Reset() {
+	d.castagnoli.Reset()
+	d.ieee.Reset()
+}
+
+func (d *dualCRC) Size() int      { return 8 }
+func (d *dualCRC) BlockSize() int { return 1 }
@@ -0,0 +1,73 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// HashOrder is the order the scanner hashes the files of a root in.
+type HashOrder int
+
+const (
+	WalkOrder HashOrder = iota
+	INodeOrder
+	ExtentOrder
+)
+
+var HashOrders = []HashOrder{WalkOrder, INodeOrder, ExtentOrder}
+
+func (o HashOrder) String() string {
+	switch o {
+	case WalkOrder:
+		return "walk"
+	case INodeOrder:
+		return "inode"
+	case ExtentOrder:
+		return "extent"
+	}
+	return "Illegal HashOrder"
+}
+
+func ParseHashOrder(name string) (HashOrder, error) {
+	for _, order := range HashOrders {
+		if order.String() == name {
+			return order, nil
+		}
+	}
+	return WalkOrder, fmt.Errorf("unknown hash order %q", name)
+}
+
+// orderFiles sorts the inodes to hash, so a spinning disk reads them with few seeks.
+// With ExtentOrder files are sorted by their first physical block where the
+// file system reports it, followed by the rest in inode order.
+func orderFiles(order HashOrder, iNodes []uint64, metas map[uint64]*m.Meta) []uint64 {
+	result := slices.Clone(iNodes)
+	switch order {
+	case INodeOrder:
+		slices.Sort(result)
+
+	case ExtentOrder:
+		offsets := map[uint64]uint64{}
+		for _, ino := range result {
+			if offset, ok := physicalOffset(metas[ino].Id.String()); ok {
+				offsets[ino] = offset
+			}
+		}
+		slices.SortFunc(result, func(a, b uint64) int {
+			offsetA, okA := offsets[a]
+			offsetB, okB := offsets[b]
+			switch {
+			case okA && okB && offsetA != offsetB:
+				return cmp.Compare(offsetA, offsetB)
+			case okA && !okB:
+				return -1
+			case !okA && okB:
+				return 1
+			}
+			return cmp.Compare(a, b)
+		})
+	}
+	return result
+}
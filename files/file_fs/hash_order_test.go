@@ -0,0 +1,47 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOrderFiles(t *testing.T) {
+	root := m.Root(t.TempDir())
+	iNodes := []uint64{30, 10, 20}
+	metas := map[uint64]*m.Meta{}
+	for _, ino := range iNodes {
+		// The files don't exist, so no physical offsets are known.
+		metas[ino] = &m.Meta{Id: m.Id{Root: root, Name: m.Path(filepath.Join("dir", "missing")).ParentName()}}
+	}
+	for order, expected := range map[HashOrder][]uint64{
+		WalkOrder:   {30, 10, 20},
+		INodeOrder:  {10, 20, 30},
+		ExtentOrder: {10, 20, 30},
+	} {
+		if result := orderFiles(order, iNodes, metas); !slices.Equal(result, expected) {
+			t.Errorf("%s: expected %v, got %v", order, expected, result)
+		}
+	}
+	if !slices.Equal(iNodes, []uint64{30, 10, 20}) {
+		t.Errorf("expected the inodes to be left alone, got %v", iNodes)
+	}
+}
+
+func TestOrderFilesExtent(t *testing.T) {
+	root := m.Root(t.TempDir())
+	entry := testFile(t, root, "a.txt", "aaaa")
+	if _, ok := physicalOffset(entry.id.String()); !ok {
+		t.Skip("the file system doesn't report physical offsets")
+	}
+	metas := map[uint64]*m.Meta{
+		entry.iNode: {Id: entry.id},
+		1:           {Id: m.Id{Root: root, Name: m.Path("missing").ParentName()}},
+	}
+	// Files with a known offset come first, even with a higher inode.
+	expected := []uint64{entry.iNode, 1}
+	if result := orderFiles(ExtentOrder, []uint64{1, entry.iNode}, metas); !slices.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,59 @@
+package file_fs
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// device runs the hashing of all the roots stored on one disk, so roots
+// sharing a spinning disk don't read from it at the same time.
+type device struct {
+	jobs chan func()
+	// queue is held while a root submits its files, so the files of one
+	// root are read in their order before those of the next.
+	queue sync.Mutex
+}
+
+func newDevice(workers int) *device {
+	d := &device{jobs: make(chan func())}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range d.jobs {
+				job()
+			}
+		}()
+	}
+	return d
+}
+
+type devices struct {
+	sync.Mutex
+	hddWorkers int
+	ssdWorkers int
+	byDev      map[uint64]*device
+}
+
+// device returns the device of the path, starting its workers on first use.
+func (d *devices) device(path string) *device {
+	d.Lock()
+	defer d.Unlock()
+
+	dev := uint64(0)
+	if info, err := os.Stat(path); err == nil {
+		dev = uint64(info.Sys().(*syscall.Stat_t).Dev)
+	}
+	if result, ok := d.byDev[dev]; ok {
+		return result
+	}
+	workers := d.hddWorkers
+	if !isRotational(dev) {
+		workers = d.ssdWorkers
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	result := newDevice(workers)
+	d.byDev[dev] = result
+	return result
+}
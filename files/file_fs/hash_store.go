@@ -0,0 +1,457 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	hashStoreName = ".arc-hashes"
+	// legacyHashStoreName is the CSV the hashes were kept in before; it is migrated on first use.
+	legacyHashStoreName = ".meta.csv"
+
+	hashStoreMagic = "arc-hashes"
+	// Version 2 added the Device and ChangeTime columns.
+	hashStoreVersion = 2
+
+	// hashStoreCheckpoint is how often new hashes are saved while hashing.
+	hashStoreCheckpoint = time.Minute
+)
+
+// hashStoreHeader names the columns of the hash store. Records are read by
+// the header, so older stores lacking columns are read as well.
+var hashStoreHeader = []string{"Device", "INode", "Name", "Size", "ModTime", "ChangeTime", "Hash", "Algorithm"}
+
+// hashEntry is a record of the hash store kept in every root.
+type hashEntry struct {
+	device  uint64
+	iNode   uint64
+	id      m.Id
+	size    uint64
+	modTime time.Time
+	// cTime helps tell a new file on a reused inode from a renamed one.
+	cTime time.Time
+	hash  m.Hash
+}
+
+// newHashEntry describes the file as it is on disk.
+func newHashEntry(id m.Id, info fs.FileInfo, hash m.Hash) hashEntry {
+	sys := info.Sys().(*syscall.Stat_t)
+	return hashEntry{
+		device:  uint64(sys.Dev),
+		iNode:   sys.Ino,
+		id:      id,
+		size:    uint64(info.Size()),
+		modTime: info.ModTime(),
+		cTime:   changeTime(sys),
+		hash:    hash,
+	}
+}
+
+// describes tells if the entry is about the file in its current state.
+func (entry hashEntry) describes(file hashEntry) bool {
+	return entry.size == file.size &&
+		entry.modTime.UTC().Round(time.Second).Equal(file.modTime.UTC().Round(time.Second))
+}
+
+// reused tells if the inode of the entry now holds another file. Renames
+// change the change time as well, so only a file under the old name on
+// another inode gives a reused inode away.
+func (entry hashEntry) reused(file hashEntry) bool {
+	if entry.id.Name == file.id.Name || entry.cTime.Equal(file.cTime) {
+		return false
+	}
+	info, err := os.Lstat(entry.id.String())
+	if err != nil {
+		return false
+	}
+	sys := info.Sys().(*syscall.Stat_t)
+	return uint64(sys.Dev) != entry.device || sys.Ino != entry.iNode
+}
+
+func (entry hashEntry) algorithm() string {
+	return algorithmName(entry.hash)
+}
+
+func (entry hashEntry) record() []string {
+	return []string{
+		fmt.Sprint(entry.device),
+		fmt.Sprint(entry.iNode),
+		norm.NFC.String(entry.id.Name.String()),
+		fmt.Sprint(entry.size),
+		entry.modTime.UTC().Format(time.RFC3339Nano),
+		entry.cTime.UTC().Format(time.RFC3339Nano),
+		entry.hash.String(),
+		entry.algorithm(),
+	}
+}
+
+type storeKey struct {
+	device    uint64
+	iNode     uint64
+	algorithm string
+}
+
+type storeName struct {
+	name      m.Name
+	algorithm string
+}
+
+// hashStore holds the hashes of the files of a root, kept in the root or in
+// the catalog directory. The file is replaced atomically on every save, and
+// its checksum tells a damaged file apart.
+type hashStore struct {
+	sync.Mutex
+	root    m.Root
+	path    string
+	catalog bool
+	entries map[storeKey]hashEntry
+	names   map[storeName]storeKey
+	changed bool
+	saved   time.Time
+	// legacy is set while the legacy store still has to be removed.
+	legacy bool
+}
+
+// hashStores shares one store per root between scans and file commands.
+type hashStores struct {
+	sync.Mutex
+	byRoot map[m.Root]*hashStore
+	// catalog are the roots whose stores are kept in the catalog directory.
+	catalog []m.Root
+}
+
+// store returns the store of the root. The store is always usable; an error
+// reports a store that could not be read and starts over empty.
+func (s *hashStores) store(root m.Root) (*hashStore, error) {
+	s.Lock()
+	defer s.Unlock()
+	if store, ok := s.byRoot[root]; ok {
+		return store, nil
+	}
+	store, err := openHashStore(root, slices.Contains(s.catalog, root))
+	s.byRoot[root] = store
+	return store, err
+}
+
+// openHashStore opens the store in the catalog directory if asked to or if
+// the root is read-only. A new store starts with the hashes of another
+// location or of the legacy store.
+func openHashStore(root m.Root, catalog bool) (*hashStore, error) {
+	store := &hashStore{
+		root:    root,
+		path:    filepath.Join(root.String(), hashStoreName),
+		catalog: catalog || !isWritable(root),
+		entries: map[storeKey]hashEntry{},
+		names:   map[storeName]storeKey{},
+		saved:   time.Now(),
+	}
+	others := catalogPaths(root)
+	if store.catalog {
+		store.path, others = others[0], append(others[1:], store.path)
+	}
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		for _, other := range others {
+			if data, err := os.ReadFile(other); err == nil && store.parse(data) == nil {
+				store.changed = true
+				return store, nil
+			}
+			store.entries = map[storeKey]hashEntry{}
+			store.names = map[storeName]storeKey{}
+		}
+		err = store.migrate()
+		return store, err
+	}
+	if err == nil {
+		err = store.parse(data)
+	}
+	if err != nil {
+		store.entries = map[storeKey]hashEntry{}
+		store.names = map[storeName]storeKey{}
+		return store, fmt.Errorf("hash store of %s: %w; files will be hashed again", root, err)
+	}
+	return store, nil
+}
+
+func (s *hashStore) legacyPath() string {
+	return filepath.Join(s.root.String(), legacyHashStoreName)
+}
+
+// parse verifies the checksum in the last line before reading the records.
+func (s *hashStore) parse(data []byte) error {
+	body, trailer := data, []byte{}
+	if idx := bytes.LastIndexByte(bytes.TrimSuffix(data, []byte("\n")), '\n'); idx >= 0 {
+		body, trailer = data[:idx+1], data[idx+1:]
+	}
+	checksum, ok := strings.CutPrefix(strings.TrimSpace(string(trailer)), "checksum,")
+	if !ok {
+		return errors.New("missing checksum")
+	}
+	if checksum != storeChecksum(body) {
+		return errors.New("checksum mismatch")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 || len(records[0]) != 2 || records[0][0] != hashStoreMagic {
+		return errors.New("not a hash store")
+	}
+	version, err := strconv.Atoi(records[0][1])
+	if err != nil || version > hashStoreVersion {
+		return fmt.Errorf("unsupported version %q", records[0][1])
+	}
+	s.addRecords(records[1:])
+	return nil
+}
+
+// migrate reads the legacy store; it is removed once the store is saved in the root.
+func (s *hashStore) migrate() error {
+	file, err := os.Open(s.legacyPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	s.addRecords(records)
+	s.changed, s.legacy = true, !s.catalog
+	return nil
+}
+
+// addRecords reads the records by the header in the first record; missing
+// devices and change times are zero and an entry without an Algorithm is SHA-256.
+func (s *hashStore) addRecords(records [][]string) {
+	if len(records) == 0 {
+		return
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	for _, record := range records[1:] {
+		device, _ := strconv.ParseUint(column(record, "Device"), 10, 64)
+		cTime, _ := time.Parse(time.RFC3339, column(record, "ChangeTime"))
+		iNode, er1 := strconv.ParseUint(column(record, "INode"), 10, 64)
+		size, er2 := strconv.ParseUint(column(record, "Size"), 10, 64)
+		modTime, er3 := time.Parse(time.RFC3339, column(record, "ModTime"))
+		hash := column(record, "Hash")
+		if hash == "" || er1 != nil || er2 != nil || er3 != nil {
+			continue
+		}
+		s.put(hashEntry{
+			device:  device,
+			iNode:   iNode,
+			id:      m.Id{Root: s.root, Name: m.Path(column(record, "Name")).ParentName()},
+			size:    size,
+			modTime: modTime,
+			cTime:   cTime,
+			hash:    m.Hash(hash),
+		})
+	}
+}
+
+// put replaces the entries of the same inode or name made by the same algorithm.
+func (s *hashStore) put(entry hashEntry) {
+	algorithm := entry.algorithm()
+	name := storeName{name: entry.id.Name, algorithm: algorithm}
+	if key, ok := s.names[name]; ok {
+		delete(s.entries, key)
+	}
+	key := storeKey{device: entry.device, iNode: entry.iNode, algorithm: algorithm}
+	if old, ok := s.entries[key]; ok {
+		delete(s.names, storeName{name: old.id.Name, algorithm: algorithm})
+	}
+	s.entries[key] = entry
+	s.names[name] = key
+}
+
+// remove drops the entry and tells if there was one.
+func (s *hashStore) remove(entry hashEntry) bool {
+	key := storeKey{device: entry.device, iNode: entry.iNode, algorithm: entry.algorithm()}
+	if _, ok := s.entries[key]; !ok {
+		return false
+	}
+	delete(s.entries, key)
+	delete(s.names, storeName{name: entry.id.Name, algorithm: key.algorithm})
+	return true
+}
+
+// hash returns the stored hash of the algorithm if the file is unchanged. The
+// device and inode find files renamed or moved since; the name finds files
+// whose inode changed, as when restored from a backup.
+func (s *hashStore) hash(file hashEntry, algorithm HashAlgorithm) (m.Hash, bool) {
+	s.Lock()
+	defer s.Unlock()
+	entry, ok := s.entries[storeKey{device: file.device, iNode: file.iNode, algorithm: algorithm.Name()}]
+	if !ok || !entry.describes(file) || entry.reused(file) {
+		key, found := s.names[storeName{name: file.id.Name, algorithm: algorithm.Name()}]
+		entry, ok = s.entries[key]
+		if !found || !ok || !entry.describes(file) {
+			return "", false
+		}
+	}
+	if entry.id != file.id || entry.device != file.device || entry.iNode != file.iNode || !entry.cTime.Equal(file.cTime) {
+		file.hash = entry.hash
+		s.remove(entry)
+		s.put(file)
+		s.changed = true
+	}
+	return entry.hash, true
+}
+
+func (s *hashStore) add(entries ...hashEntry) {
+	s.Lock()
+	defer s.Unlock()
+	for _, entry := range entries {
+		s.put(entry)
+	}
+	s.changed = s.changed || len(entries) > 0
+}
+
+// renamed moves the entries of the name to the renamed file, whose change time is new.
+func (s *hashStore) renamed(from m.Name, file hashEntry) {
+	s.Lock()
+	defer s.Unlock()
+	for _, algorithm := range s.algorithms() {
+		entry, ok := s.entries[s.names[storeName{name: from, algorithm: algorithm}]]
+		if !ok || entry.id.Name != from || !entry.describes(file) {
+			continue
+		}
+		s.remove(entry)
+		file.hash = entry.hash
+		s.put(file)
+		s.changed = true
+	}
+}
+
+func (s *hashStore) algorithms() []string {
+	result := []string{}
+	for key := range s.entries {
+		if !slices.Contains(result, key.algorithm) {
+			result = append(result, key.algorithm)
+		}
+	}
+	return result
+}
+
+// prune drops the entries of files that are gone; only a complete scan knows them.
+func (s *hashStore) prune(files map[uint64]hashEntry) {
+	s.Lock()
+	defer s.Unlock()
+	for key, entry := range s.entries {
+		if file, ok := files[key.iNode]; !ok || file.device != key.device {
+			s.remove(entry)
+			s.changed = true
+		}
+	}
+}
+
+// checkpoint saves the store if the last save is older than hashStoreCheckpoint.
+func (s *hashStore) checkpoint() error {
+	s.Lock()
+	defer s.Unlock()
+	if time.Since(s.saved) < hashStoreCheckpoint {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+func (s *hashStore) save() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.saveLocked()
+}
+
+func (s *hashStore) saveLocked() error {
+	if !s.changed {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	writer.Write([]string{hashStoreMagic, strconv.Itoa(hashStoreVersion)})
+	writer.Write(hashStoreHeader)
+	for _, entry := range s.entries {
+		writer.Write(entry.record())
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	body := buf.Bytes()
+	err := writeAtomic(s.path, func(file io.Writer) error {
+		_, err := fmt.Fprintf(file, "%schecksum,%s\n", body, storeChecksum(body))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.changed, s.saved = false, time.Now()
+	if s.legacy {
+		os.Remove(s.legacyPath())
+		s.legacy = false
+	}
+	return nil
+}
+
+func storeChecksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// renamed keeps the stored hashes of a file renamed by a command.
+func (f *fileFs) renamed(from m.Id, file hashEntry) {
+	store, err := f.stores.store(from.Root)
+	if err != nil {
+		f.events.Push(m.Error{Error: err})
+	}
+	store.renamed(from.Name, file)
+	if err := store.save(); err != nil {
+		f.events.Push(m.Error{Id: file.id, Error: err})
+	}
+}
+
+// addHashes records the entries in the hash store of the root and saves it.
+func (f *fileFs) addHashes(root m.Root, entries ...hashEntry) error {
+	store, err := f.stores.store(root)
+	if err != nil {
+		f.events.Push(m.Error{Error: err})
+	}
+	store.add(entries...)
+	return store.save()
+}
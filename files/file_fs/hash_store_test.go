@@ -0,0 +1,196 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFile(t *testing.T, root m.Root, name, content string) hashEntry {
+	t.Helper()
+	path := filepath.Join(root.String(), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return statFile(t, root, name)
+}
+
+func statFile(t *testing.T, root m.Root, name string) hashEntry {
+	t.Helper()
+	id := m.Id{Root: root, Name: m.Path(name).ParentName()}
+	info, err := os.Lstat(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newHashEntry(id, info, "")
+}
+
+func newTestStore(t *testing.T, root m.Root) *hashStore {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	store, err := openHashStore(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestHashStoreRenamed(t *testing.T) {
+	root := m.Root(t.TempDir())
+	store := newTestStore(t, root)
+	entry := testFile(t, root, "a.txt", "aaaa")
+	entry.hash = "aaaa"
+	store.add(entry)
+
+	// rename(2) changes the change time, as a move outside of arc does.
+	time.Sleep(10 * time.Millisecond)
+	if err := os.MkdirAll(filepath.Join(root.String(), "moved"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root.String(), "a.txt"), filepath.Join(root.String(), "moved", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if hash, ok := store.hash(statFile(t, root, "moved/b.txt"), SHA256); !ok || hash != "aaaa" {
+		t.Errorf("expected the hash of the moved file, got %q, %v", hash, ok)
+	}
+}
+
+func TestHashStoreReusedINode(t *testing.T) {
+	root := m.Root(t.TempDir())
+	store := newTestStore(t, root)
+	testFile(t, root, "a.txt", "aaaa")
+	other := testFile(t, root, "c.txt", "cccc")
+
+	// The entry of a.txt had the inode c.txt has now, but a.txt is back on another inode.
+	stale := other
+	stale.id = m.Id{Root: root, Name: m.Path("a.txt").ParentName()}
+	stale.cTime = other.cTime.Add(-time.Hour)
+	stale.hash = "aaaa"
+	store.add(stale)
+
+	if hash, ok := store.hash(other, SHA256); ok {
+		t.Errorf("expected no hash for a file on a reused inode, got %q", hash)
+	}
+}
+
+// savedStore saves a store holding the hash of a.txt and returns the path of the store.
+func savedStore(t *testing.T, root m.Root) string {
+	t.Helper()
+	store := newTestStore(t, root)
+	entry := testFile(t, root, "a.txt", "aaaa")
+	entry.hash = "aaaa"
+	store.add(entry)
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	return store.path
+}
+
+// reopened opens the store of the root again and returns the stored hash of a.txt.
+func reopened(t *testing.T, root m.Root, catalog bool) (m.Hash, bool, error) {
+	t.Helper()
+	store, err := openHashStore(root, catalog)
+	hash, ok := store.hash(statFile(t, root, "a.txt"), SHA256)
+	return hash, ok, err
+}
+
+func TestHashStoreRoundTrip(t *testing.T) {
+	root := m.Root(t.TempDir())
+	path := savedStore(t, root)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != "arc-hashes,2" || !strings.HasPrefix(lines[len(lines)-1], "checksum,") {
+		t.Errorf("unexpected store:\n%s", data)
+	}
+	if hash, ok, err := reopened(t, root, false); err != nil || !ok || hash != "aaaa" {
+		t.Errorf("expected the stored hash, got %q, %v, %v", hash, ok, err)
+	}
+}
+
+func TestHashStoreDamaged(t *testing.T) {
+	for name, damage := range map[string]func([]byte) []byte{
+		"changed":   func(data []byte) []byte { data[len(data)/2] ^= 1; return data },
+		"truncated": func(data []byte) []byte { return data[:len(data)-20] },
+		"no lines":  func(data []byte) []byte { return data[:10] },
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := m.Root(t.TempDir())
+			path := savedStore(t, root)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, damage(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if hash, ok, err := reopened(t, root, false); err == nil || ok {
+				t.Errorf("expected an error and no hash, got %q, %v, %v", hash, ok, err)
+			}
+		})
+	}
+}
+
+func TestHashStoreNewerVersion(t *testing.T) {
+	root := m.Root(t.TempDir())
+	path := savedStore(t, root)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, _ := strings.Cut(string(data), "checksum,")
+	body = strings.Replace(body, "arc-hashes,2", fmt.Sprintf("arc-hashes,%d", hashStoreVersion+1), 1)
+	data = []byte(body + "checksum," + storeChecksum([]byte(body)) + "\n")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, ok, err := reopened(t, root, false)
+	if err == nil || !strings.Contains(err.Error(), "unsupported version") || ok {
+		t.Errorf("expected an unsupported version, got %q, %v, %v", hash, ok, err)
+	}
+}
+
+func TestHashStoreMigrate(t *testing.T) {
+	for _, catalog := range []bool{false, true} {
+		t.Run(fmt.Sprint("catalog ", catalog), func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", t.TempDir())
+			root := m.Root(t.TempDir())
+			testFile(t, root, "a.txt", "aaaa")
+			modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := os.Chtimes(filepath.Join(root.String(), "a.txt"), modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			legacy := filepath.Join(root.String(), legacyHashStoreName)
+			content := fmt.Sprintf("INode,Name,Size,ModTime,Hash\n%d,a.txt,4,%s,aaaa\n",
+				statFile(t, root, "a.txt").iNode, modTime.Format(time.RFC3339))
+			if err := os.WriteFile(legacy, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := openHashStore(root, catalog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.save(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(legacy); os.IsNotExist(err) == catalog {
+				t.Errorf("expected the legacy store to be removed only from a root store, got %v", err)
+			}
+			if hash, ok, err := reopened(t, root, catalog); err != nil || !ok || hash != "aaaa" {
+				t.Errorf("expected the migrated hash, got %q, %v, %v", hash, ok, err)
+			}
+		})
+	}
+}
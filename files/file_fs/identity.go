@@ -0,0 +1,87 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const identityFileName = ".arc-id"
+
+// InitArchive writes a new identity into the root; a root is initialized once.
+func InitArchive(root m.Root, label string) (m.Identity, error) {
+	if identity, err := ReadIdentity(root); err == nil {
+		return identity, fmt.Errorf("%s is already initialized as %q (%s)", root, identity.Label, identity.Id)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return m.Identity{}, err
+	}
+	id, err := newUUID()
+	if err != nil {
+		return m.Identity{}, err
+	}
+	identity := m.Identity{Id: id, Label: label, Created: time.Now().UTC().Round(time.Second)}
+	err = writeAtomic(filepath.Join(root.String(), identityFileName), func(file io.Writer) error {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(identity)
+	})
+	return identity, err
+}
+
+// ReadIdentity returns the identity of the root, or fs.ErrNotExist if it was never initialized.
+func ReadIdentity(root m.Root) (m.Identity, error) {
+	data, err := os.ReadFile(filepath.Join(root.String(), identityFileName))
+	if err != nil {
+		return m.Identity{}, err
+	}
+	identity := m.Identity{}
+	if err := json.Unmarshal(data, &identity); err != nil || identity.Id == "" {
+		return m.Identity{}, fmt.Errorf("%s has a damaged %s", root, identityFileName)
+	}
+	return identity, nil
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() (string, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+// rootsByKey maps the keys and the paths of the roots to the roots.
+func rootsByKey(roots []m.Root) map[string]m.Root {
+	byKey := map[string]m.Root{}
+	for _, root := range roots {
+		byKey[root.String()] = root
+		byKey[rootKey(root)] = root
+	}
+	return byKey
+}
+
+// keyedRoot finds the root by its key, or by its path when no key was recorded.
+func keyedRoot(byKey map[string]m.Root, key string, root m.Root) (m.Root, bool) {
+	if key == "" {
+		key = root.String()
+	}
+	root, ok := byKey[key]
+	return root, ok
+}
+
+// rootKey is the identity of an initialized root, or else its path.
+func rootKey(root m.Root) string {
+	if identity, err := ReadIdentity(root); err == nil {
+		return "id:" + identity.Id
+	}
+	return root.String()
+}
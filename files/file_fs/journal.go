@@ -0,0 +1,104 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func journalPath() string {
+	return filepath.Join(DataDir(), "journal.jsonl")
+}
+
+// record appends the entry to the journal and syncs it before the next command runs.
+func (f *fileFs) record(entry m.JournalEntry) {
+	f.journaled++
+	entry.Id = fmt.Sprintf("%s-%d", f.session, f.journaled)
+	entry.Session = f.session
+	entry.Time = time.Now().UTC()
+	if entry.From.Root != "" {
+		entry.FromKey = rootKey(entry.From.Root)
+	}
+	for _, to := range entry.To {
+		entry.ToKeys = append(entry.ToKeys, rootKey(to.Root))
+	}
+	err := appendJournal(entry)
+	if err != nil {
+		f.events.Push(m.Error{Error: fmt.Errorf("failed to journal %s of %q: %w", entry.Op, entry.From.Name, err)})
+		return
+	}
+	f.events.Push(m.Journaled{Entry: entry})
+}
+
+func appendJournal(entry m.JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(journalPath()), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(journalPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadJournal returns the entries touching any of the roots; the undo entries
+// are kept. Roots are found by their keys, and the entries get their current paths.
+func ReadJournal(roots []m.Root) ([]m.JournalEntry, error) {
+	file, err := os.Open(journalPath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	byKey := rootsByKey(roots)
+	result := []m.JournalEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		entry := m.JournalEntry{}
+		// A torn last line of a crashed run is skipped.
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if entry.Op == "undo" {
+			result = append(result, entry)
+			continue
+		}
+		root, ok := keyedRoot(byKey, entry.FromKey, entry.From.Root)
+		if !ok {
+			continue
+		}
+		entry.From.Root = root
+		for i, to := range entry.To {
+			key := ""
+			if i < len(entry.ToKeys) {
+				key = entry.ToKeys[i]
+			}
+			if root, ok := keyedRoot(byKey, key, to.Root); ok {
+				entry.To[i].Root = root
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, scanner.Err()
+}
+
+func (f *fileFs) markUndone(undone m.MarkUndone) {
+	f.record(m.NewUndo(undone.Ids))
+}
@@ -0,0 +1,69 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"arc/stream"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// movedRoots initializes two roots and records an operation and an
+// interrupted copy between them, then moves the first root.
+func movedRoots(t *testing.T) (origin, moved, copy m.Root) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+	origin, moved, copy = m.Root(filepath.Join(dir, "origin")), m.Root(filepath.Join(dir, "moved")), m.Root(filepath.Join(dir, "copy"))
+	for _, root := range []m.Root{origin, copy} {
+		if err := os.Mkdir(root.String(), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := InitArchive(root, root.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := sessionAt(time.Now())
+	f.events = stream.NewStream[m.Event]("test")
+	f.record(m.JournalEntry{Operation: m.Operation{Op: "copy", From: planId(origin, "a.txt"), To: []m.PlanId{planId(copy, "a.txt")}}})
+	from, to := m.Id{Root: origin, Name: m.Path("a.txt").ParentName()}, m.Id{Root: copy, Name: m.Path("a.txt").ParentName()}
+	if err := writeCopyState(m.InterruptedCopy{From: from, To: to, Hash: "aaaa", Committed: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(origin.String(), moved.String()); err != nil {
+		t.Fatal(err)
+	}
+	return origin, moved, copy
+}
+
+func TestReadJournalMovedRoot(t *testing.T) {
+	origin, moved, copy := movedRoots(t)
+
+	entries, err := ReadJournal([]m.Root{moved, copy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].From.Root != moved || entries[0].To[0].Root != copy {
+		t.Errorf("expected the entry from %q, got %v", moved, entries)
+	}
+	if entries, err := ReadJournal([]m.Root{origin}); err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries for the old path, got %v, %v", entries, err)
+	}
+}
+
+func TestInterruptedCopiesMovedRoot(t *testing.T) {
+	_, moved, copy := movedRoots(t)
+
+	copies, err := interruptedCopies([]m.Root{moved, copy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 1 || copies[0].From.Root != moved || copies[0].To.Root != copy {
+		t.Fatalf("expected the copy from %q, got %v", moved, copies)
+	}
+	resume := m.CopyFile{From: copies[0].From, To: []m.Id{copies[0].To}, Hash: "aaaa"}
+	if offset := resumeOffset(resume, copies[0].To); offset != 4 {
+		t.Errorf("expected to resume at 4, got %d", offset)
+	}
+}
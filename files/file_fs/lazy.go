@@ -0,0 +1,99 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"encoding/binary"
+	"os"
+	"sync"
+)
+
+const (
+	// partialBlockSize is read from the head, the middle and the tail of a file.
+	partialBlockSize = 64 * 1024
+
+	// Files up to partialMinSize are hashed in full even when a partial hash is asked for.
+	partialMinSize = 16 * partialBlockSize
+)
+
+// hashFiles hashes the files on the worker pools of their devices and seeds
+// the hash stores with the full hashes.
+func (f *fileFs) hashFiles(cmd m.HashFiles) {
+	defer func() {
+		f.events.Push(m.FilesHashed{Partial: cmd.Partial})
+	}()
+
+	type result struct {
+		id      m.Id
+		hash    m.Hash
+		partial bool
+		entry   hashEntry
+	}
+	results := make(chan result)
+	go func() {
+		wg := sync.WaitGroup{}
+		for _, id := range cmd.Ids {
+			if f.lc.ShoudStop() {
+				break
+			}
+			id := id
+			s := &scanner{root: id.Root, events: f.events, lc: f.lc, algorithm: f.algorithm}
+			wg.Add(1)
+			f.devices.device(id.Root.String()).jobs <- func() {
+				defer wg.Done()
+				info, err := os.Stat(id.String())
+				if err != nil {
+					f.events.Push(m.Error{Id: id, Error: err})
+					return
+				}
+				if cmd.Partial && info.Size() > partialMinSize {
+					results <- result{id: id, hash: s.partialHash(id, info.Size()), partial: true}
+					return
+				}
+				hash := s.hashFile(id)
+				results <- result{id: id, hash: hash, entry: newHashEntry(id, info, hash)}
+			}
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	entries := map[m.Root][]hashEntry{}
+	for result := range results {
+		if f.lc.ShoudStop() || result.hash == "" {
+			continue
+		}
+		if result.partial {
+			f.events.Push(m.PartialHashed{Id: result.id, Hash: result.hash})
+			continue
+		}
+		entries[result.id.Root] = append(entries[result.id.Root], result.entry)
+		f.events.Push(m.FileHashed{Id: result.id, Hash: result.hash})
+	}
+	for root, entries := range entries {
+		if err := f.addHashes(root, entries...); err != nil {
+			f.events.Push(m.Error{Error: err})
+		}
+	}
+}
+
+// partialHash hashes the size and three blocks of the file.
+func (s *scanner) partialHash(id m.Id, size int64) m.Hash {
+	file, err := os.Open(id.String())
+	if err != nil {
+		s.events.Push(m.Error{Id: id, Error: err})
+		return ""
+	}
+	defer file.Close()
+
+	hash := s.algorithm.New()
+	binary.Write(hash, binary.BigEndian, size)
+	buf := make([]byte, partialBlockSize)
+	for _, offset := range []int64{0, (size - partialBlockSize) / 2, size - partialBlockSize} {
+		if _, err := file.ReadAt(buf, offset); err != nil {
+			s.events.Push(m.Error{Id: id, Error: err})
+			return ""
+		}
+		hash.Write(buf)
+	}
+	return encodeHash(s.algorithm, hash.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+func (fs *fileFs) storePlan(cmd m.StorePlan) {
+	err := WritePlan(cmd.Path, cmd.Plan)
+	if err != nil {
+		fs.events.Push(m.Error{Error: err})
+		return
+	}
+	fs.events.Push(m.PlanStored{Path: cmd.Path})
+}
+
+func ReadPlan(path string) (m.Plan, error) {
+	var plan m.Plan
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return plan, err
+	}
+	err = json.Unmarshal(data, &plan)
+	return plan, err
+}
+
+func WritePlan(path string, plan m.Plan) error {
+	return writeAtomic(path, func(file io.Writer) error {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	})
+}
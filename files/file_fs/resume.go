@@ -0,0 +1,73 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkpointSize is how much a copy writes between two persisted checkpoints.
+const checkpointSize = 64 << 20
+
+// copyStatePath is keyed by the keys of the roots, so a copy resumes after a root moved.
+func copyStatePath(from, to m.Id) string {
+	key := sha256.Sum256([]byte(strings.Join([]string{rootKey(from.Root), from.Name.String(), rootKey(to.Root), to.Name.String()}, "\n")))
+	return filepath.Join(DataDir(), "copies", base64.RawURLEncoding.EncodeToString(key[:])+".json")
+}
+
+func readCopyState(path string) (m.InterruptedCopy, error) {
+	var state m.InterruptedCopy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+func writeCopyState(state m.InterruptedCopy) error {
+	state.FromKey, state.ToKey = rootKey(state.From.Root), rootKey(state.To.Root)
+	return writeAtomic(copyStatePath(state.From, state.To), func(file io.Writer) error {
+		return json.NewEncoder(file).Encode(state)
+	})
+}
+
+// resumeOffset is where the copy to the target can continue, or zero for a fresh copy.
+func resumeOffset(copy m.CopyFile, to m.Id) uint64 {
+	state, err := readCopyState(copyStatePath(copy.From, to))
+	if err != nil || state.Hash != copy.Hash || copy.Hash == "" {
+		return 0
+	}
+	return state.Committed
+}
+
+// interruptedCopies lists the copies left over from an earlier run that read from the roots.
+func interruptedCopies(roots []m.Root) ([]m.InterruptedCopy, error) {
+	entries, err := os.ReadDir(filepath.Join(DataDir(), "copies"))
+	if err != nil {
+		return nil, err
+	}
+	byKey := rootsByKey(roots)
+	result := []m.InterruptedCopy{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		state, err := readCopyState(filepath.Join(DataDir(), "copies", entry.Name()))
+		if err != nil {
+			continue
+		}
+		from, okFrom := keyedRoot(byKey, state.FromKey, state.From.Root)
+		to, okTo := keyedRoot(byKey, state.ToKey, state.To.Root)
+		if okFrom && okTo {
+			state.From.Root, state.To.Root = from, to
+			result = append(result, state)
+		}
+	}
+	return result, nil
+}
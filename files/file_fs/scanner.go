@@ -4,29 +4,27 @@ import (
 	"arc/lifecycle"
 	m "arc/model"
 	"arc/stream"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/csv"
-	"fmt"
 	"io"
 	"io/fs"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
-
-	"golang.org/x/text/unicode/norm"
 )
 
-const hashFileName = ".meta.csv"
-
 type scanner struct {
 	root   m.Root
 	events *stream.Stream[m.Event]
 	lc     *lifecycle.Lifecycle
-	metas  map[uint64]*m.Meta
+	device *device
+	order  HashOrder
+	lazy   bool
+
+	algorithm HashAlgorithm
+	store     *hashStore
+	metas     map[uint64]*m.Meta
+	// files describe the files on disk for the hash store.
+	files  map[uint64]hashEntry
 	hashes map[uint64]m.Hash
 	iNodes []uint64
 }
@@ -38,38 +36,47 @@ func (s *scanner) scanArchive() {
 		})
 	}()
 
+	walked := true
 	fsys := os.DirFS(s.root.String())
 	fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
-		if s.lc.ShoudStop() || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+		// Files under a folder that can't be read are unknown, not gone,
+		// so any error keeps their stored hashes from being pruned.
+		if s.lc.ShoudStop() {
+			walked = false
 			return nil
 		}
-
 		if err != nil {
+			walked = false
 			s.events.Push(m.Error{
 				Id:    m.Id{Root: s.root, Name: m.Path(path).ParentName()},
 				Error: err})
 			return nil
 		}
+		if d.IsDir() && path != "." && strings.HasPrefix(d.Name(), ".") {
+			return fs.SkipDir
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
 
 		meta, err := d.Info()
 		if err != nil {
+			walked = false
 			s.events.Push(m.Error{
 				Id:    m.Id{Root: s.root, Name: m.Path(path).ParentName()},
 				Error: err})
 			return nil
 		}
-		sys := meta.Sys().(*syscall.Stat_t)
-		modTime := meta.ModTime()
-		modTime = modTime.UTC().Round(time.Second)
-
+		entry := newHashEntry(m.Id{Root: s.root, Name: m.Path(path).ParentName()}, meta, "")
 		file := &m.Meta{
-			Id:      m.Id{Root: s.root, Name: m.Path(path).ParentName()},
-			ModTime: modTime,
-			Size:    uint64(meta.Size()),
+			Id:      entry.id,
+			ModTime: entry.modTime.UTC().Round(time.Second),
+			Size:    entry.size,
 		}
 
-		s.metas[sys.Ino] = file
-		s.iNodes = append(s.iNodes, sys.Ino)
+		s.metas[entry.iNode] = file
+		s.files[entry.iNode] = entry
+		s.iNodes = append(s.iNodes, entry.iNode)
 
 		s.events.Push(m.FileScanned{
 			Meta: *file,
@@ -82,35 +89,82 @@ func (s *scanner) scanArchive() {
 		Root: s.root,
 	})
 
-	s.readMeta()
+	for _, ino := range s.iNodes {
+		file := s.metas[ino]
+		if hash, ok := s.store.hash(s.files[ino], s.algorithm); ok {
+			s.hashes[ino] = hash
+			s.events.Push(m.FileHashed{Id: file.Id, Hash: hash})
+		}
+	}
 	defer func() {
-		s.storeMeta()
+		if walked {
+			s.store.prune(s.files)
+		}
+		if err := s.store.save(); err != nil {
+			s.events.Push(m.Error{Error: err})
+		}
 	}()
 
-	for ino, hash := range s.hashes {
-		file := s.metas[ino]
-		s.events.Push(m.FileHashed{Id: file.Id, Hash: hash})
+	// A lazy scan leaves hashing to the HashFiles commands.
+	if s.lazy {
+		return
 	}
 
+	pending := []uint64{}
 	for _, ino := range s.iNodes {
-		if _, ok := s.hashes[ino]; ok {
+		if _, ok := s.hashes[ino]; !ok {
+			pending = append(pending, ino)
+		}
+	}
+	pending = orderFiles(s.order, pending, s.metas)
+	results := make(chan hashResult)
+	go s.hashFiles(pending, results)
+	for result := range results {
+		if s.lc.ShoudStop() {
 			continue
 		}
-		file := s.metas[ino]
+		file := s.metas[result.iNode]
+		s.hashes[result.iNode] = result.hash
+		s.events.Push(m.FileHashed{Id: file.Id, Hash: result.hash})
+		if result.hash == "" {
+			continue
+		}
+		entry := s.files[result.iNode]
+		entry.hash = result.hash
+		s.store.add(entry)
+		if err := s.store.checkpoint(); err != nil {
+			s.events.Push(m.Error{Error: err})
+		}
+	}
+}
 
-		hash := s.hashFile(file.Id)
-		s.hashes[ino] = hash
+type hashResult struct {
+	iNode uint64
+	hash  m.Hash
+}
 
+// hashFiles queues the files without hashes on the device of the root.
+func (s *scanner) hashFiles(iNodes []uint64, results chan hashResult) {
+	wg := sync.WaitGroup{}
+	s.device.queue.Lock()
+	for _, ino := range iNodes {
 		if s.lc.ShoudStop() {
-			return
+			break
+		}
+		ino, id := ino, s.metas[ino].Id
+		wg.Add(1)
+		s.device.jobs <- func() {
+			defer wg.Done()
+			results <- hashResult{iNode: ino, hash: s.hashFile(id)}
 		}
-
-		s.events.Push(m.FileHashed{Id: file.Id, Hash: hash})
 	}
+	s.device.queue.Unlock()
+	wg.Wait()
+	close(results)
 }
 
 func (s *scanner) hashFile(id m.Id) m.Hash {
-	hash := sha256.New()
+	hash := s.algorithm.New()
 	buf := make([]byte, 1024*1024)
 	var hashed uint64
 
@@ -156,63 +210,5 @@ func (s *scanner) hashFile(id m.Id) m.Hash {
 			Hashed: hashed,
 		})
 	}
-	return m.Hash(base64.RawURLEncoding.EncodeToString(hash.Sum(nil)))
-}
-
-func (s *scanner) readMeta() {
-	absHashFileName := filepath.Join(s.root.String(), hashFileName)
-	hashInfoFile, err := os.Open(absHashFileName)
-	if err != nil {
-		return
-	}
-	defer hashInfoFile.Close()
-
-	records, err := csv.NewReader(hashInfoFile).ReadAll()
-	if err != nil || len(records) == 0 {
-		return
-	}
-
-	for _, record := range records[1:] {
-		if len(record) == 5 {
-			iNode, er1 := strconv.ParseUint(record[0], 10, 64)
-			size, er2 := strconv.ParseUint(record[2], 10, 64)
-			modTime, er3 := time.Parse(time.RFC3339, record[3])
-			modTime = modTime.UTC().Round(time.Second)
-			hash := record[4]
-			if hash == "" || er1 != nil || er2 != nil || er3 != nil {
-				continue
-			}
-
-			info, ok := s.metas[iNode]
-			if hash != "" && ok && info.ModTime == modTime && info.Size == size {
-				s.hashes[iNode] = m.Hash(hash)
-			}
-		}
-	}
-}
-
-func (s *scanner) storeMeta() error {
-	result := make([][]string, 1, len(s.metas)+1)
-	result[0] = []string{"INode", "Name", "Size", "ModTime", "Hash"}
-
-	for iNode, hash := range s.hashes {
-		file := s.metas[iNode]
-		result = append(result, []string{
-			fmt.Sprint(iNode),
-			norm.NFC.String(file.Name.String()),
-			fmt.Sprint(file.Size),
-			file.ModTime.UTC().Format(time.RFC3339Nano),
-			hash.String(),
-		})
-	}
-
-	absHashFileName := filepath.Join(s.root.String(), hashFileName)
-	hashInfoFile, err := os.Create(absHashFileName)
-
-	if err != nil {
-		return err
-	}
-	err = csv.NewWriter(hashInfoFile).WriteAll(result)
-	hashInfoFile.Close()
-	return err
+	return encodeHash(s.algorithm, hash.Sum(nil))
 }
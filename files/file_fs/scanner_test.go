@@ -0,0 +1,89 @@
+package file_fs
+
+import (
+	"arc/lifecycle"
+	m "arc/model"
+	"arc/stream"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// syntheticTree writes files in a random order across folders, so walk order,
+// inode order and disk order differ the way they do in an aged archive.
+// The page cache hides seeks: compare cold scans after dropping the caches.
+func syntheticTree(b *testing.B, files, size int) (m.Root, []uint64, map[uint64]*m.Meta) {
+	root := b.TempDir()
+	data := make([]byte, size)
+	for _, i := range rand.New(rand.NewSource(1)).Perm(files) {
+		path := filepath.Join(root, fmt.Sprintf("d%02d", i%16), fmt.Sprintf("f%04d", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	iNodes := []uint64{}
+	metas := map[uint64]*m.Meta{}
+	fs.WalkDir(os.DirFS(root), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		ino := info.Sys().(*syscall.Stat_t).Ino
+		iNodes = append(iNodes, ino)
+		metas[ino] = &m.Meta{Id: m.Id{Root: m.Root(root), Name: m.Path(path).ParentName()}, Size: uint64(info.Size())}
+		return nil
+	})
+	return m.Root(root), iNodes, metas
+}
+
+func BenchmarkHashOrder(b *testing.B) {
+	const files, size = 256, 256 * 1024
+	root, iNodes, metas := syntheticTree(b, files, size)
+	for _, order := range HashOrders {
+		b.Run(order.String(), func(b *testing.B) {
+			b.SetBytes(files * size)
+			for i := 0; i < b.N; i++ {
+				s := &scanner{root: root, events: stream.NewStream[m.Event]("bench"), lc: lifecycle.New(), algorithm: SHA256}
+				for _, ino := range orderFiles(order, iNodes, metas) {
+					if s.hashFile(metas[ino].Id) == "" {
+						b.Fatalf("failed to hash %s", metas[ino].Id)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestScanFailedWalk scans a root that can't be read: the stored hashes
+// must survive, as the files may well be there the next time.
+func TestScanFailedWalk(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	root := m.Root(filepath.Join(t.TempDir(), "unmounted"))
+	store, _ := openHashStore(root, true)
+	store.add(hashEntry{iNode: 1, id: m.Id{Root: root, Name: m.Path("a.txt").ParentName()}, size: 1, hash: "aaaa"})
+	s := &scanner{
+		root:      root,
+		events:    stream.NewStream[m.Event]("test"),
+		lc:        lifecycle.New(),
+		device:    newDevice(1),
+		algorithm: SHA256,
+		store:     store,
+		metas:     map[uint64]*m.Meta{},
+		files:     map[uint64]hashEntry{},
+		hashes:    map[uint64]m.Hash{},
+	}
+	s.scanArchive()
+	if len(store.entries) != 1 {
+		t.Errorf("expected the stored hash to be kept, got %v", store.entries)
+	}
+}
@@ -0,0 +1,125 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const scriptHeader = `#!/bin/sh
+# Generated by arc. Every step checks the state it expects,
+# so the script can be run again after a partial run.
+set -eu
+
+# matches FILE SHA256 tells if the file has the expected content.
+matches() {
+	[ "$(sha256sum < "$1" | cut -d ' ' -f 1)" = "$2" ]
+}
+
+# check FILE SHA256 stops the script unless the file has the expected content.
+check() {
+	if ! matches "$1" "$2"; then
+		echo "arc: $1 does not match the plan" >&2
+		exit 1
+	fi
+}
+
+`
+
+// WriteScript writes the plan as a POSIX shell script using cp, mv and rm.
+func WriteScript(out io.Writer, plan m.Plan) error {
+	buf := &strings.Builder{}
+	buf.WriteString(scriptHeader)
+	for _, op := range plan.Operations {
+		cmd, err := op.Command()
+		if err != nil {
+			return err
+		}
+		var hash string
+		if op.Hash != "" {
+			hash, err = hexHash(op.Hash)
+			if err != nil {
+				return err
+			}
+		}
+		switch cmd := cmd.(type) {
+		case m.DeleteFile:
+			path := quote(cmd.Id.String())
+			fmt.Fprintf(buf, "# delete %q\n", cmd.Id)
+			// A later step may have put new content under the name already,
+			// so other content is left alone instead of stopping a second run.
+			fmt.Fprintf(buf, "if [ -e %s ]; then\n", path)
+			fmt.Fprintf(buf, "\tif matches %s %s; then\n", path, hash)
+			fmt.Fprintf(buf, "\t\trm -- %s\n", path)
+			fmt.Fprintf(buf, "\telse\n")
+			fmt.Fprintf(buf, "\t\tprintf 'arc: keeping %%s, it does not match the plan\\n' %s >&2\n", path)
+			fmt.Fprintf(buf, "\tfi\n")
+			fmt.Fprintf(buf, "fi\n\n")
+
+		case m.RenameFile:
+			to := m.Id{Root: cmd.From.Root, Name: cmd.To}
+			from, toPath := quote(cmd.From.String()), quote(to.String())
+			fmt.Fprintf(buf, "# rename %q -> %q\n", cmd.From, to)
+			fmt.Fprintf(buf, "if [ -e %s ] && [ ! -e %s ]; then\n", from, toPath)
+			fmt.Fprintf(buf, "\tcheck %s %s\n", from, hash)
+			fmt.Fprintf(buf, "\tmkdir -p -- %s\n", quote(filepath.Dir(to.String())))
+			fmt.Fprintf(buf, "\tmv -- %s %s\n", from, toPath)
+			fmt.Fprintf(buf, "fi\n\n")
+
+		case m.RenameFolder:
+			from := filepath.Join(cmd.Root.String(), cmd.From.String())
+			to := filepath.Join(cmd.Root.String(), cmd.To.String())
+			fmt.Fprintf(buf, "# move %q -> %q\n", from, to)
+			fmt.Fprintf(buf, "if [ -d %s ] && [ ! -e %s ]; then\n", quote(from), quote(to))
+			for _, file := range op.Files {
+				fileHash, err := hexHash(file.Hash)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(buf, "\tcheck %s %s\n", quote(filepath.Join(cmd.Root.String(), file.Name)), fileHash)
+			}
+			fmt.Fprintf(buf, "\tmkdir -p -- %s\n", quote(filepath.Dir(to)))
+			fmt.Fprintf(buf, "\tmv -- %s %s\n", quote(from), quote(to))
+			fmt.Fprintf(buf, "fi\n\n")
+
+		case m.CopyFile:
+			from := quote(cmd.From.String())
+			modTime := op.ModTime.UTC().Format(time.RFC3339)
+			for _, to := range cmd.To {
+				toPath, tmpPath := quote(to.String()), quote(copyTempPath(to))
+				fmt.Fprintf(buf, "# copy %q -> %q\n", cmd.From, to)
+				fmt.Fprintf(buf, "if [ ! -e %s ]; then\n", toPath)
+				fmt.Fprintf(buf, "\tcheck %s %s\n", from, hash)
+				fmt.Fprintf(buf, "\tmkdir -p -- %s\n", quote(filepath.Dir(to.String())))
+				fmt.Fprintf(buf, "\tcp -- %s %s\n", from, tmpPath)
+				fmt.Fprintf(buf, "\ttouch -d %s -- %s\n", modTime, tmpPath)
+				fmt.Fprintf(buf, "\tmv -- %s %s\n", tmpPath, toPath)
+				fmt.Fprintf(buf, "fi\n")
+				fmt.Fprintf(buf, "check %s %s\n\n", toPath, hash)
+			}
+		}
+	}
+	_, err := io.WriteString(out, buf.String())
+	return err
+}
+
+func hexHash(hash m.Hash) (string, error) {
+	if name := algorithmName(hash); name != SHA256.Name() {
+		return "", fmt.Errorf("scripts check files by their SHA-256 hashes, but the plan has %s hashes; make the plan with -hash sha256", name)
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(hash.String())
+	if err != nil || len(sum) != 32 {
+		return "", fmt.Errorf("hash %q is not a SHA-256 hash", hash)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// quote makes any file name safe to use as a single shell word.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
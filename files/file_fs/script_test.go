@@ -0,0 +1,134 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func contentHash(content string) m.Hash {
+	sum := sha256.Sum256([]byte(content))
+	return m.Hash(base64.RawURLEncoding.EncodeToString(sum[:]))
+}
+
+func planId(root m.Root, name string) m.PlanId {
+	return m.PlanId{Root: root, Name: name}
+}
+
+func needShell(t *testing.T) {
+	for _, tool := range []string{"sh", "sha256sum", "touch"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not available", tool)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	needShell(t)
+	for _, name := range []string{"plain", "with space", "it's", `"double"`, "$(touch pwned)", "back\\slash", "new\nline", "-dash", "*"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+quote(name)).Output()
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if string(out) != name {
+			t.Errorf("expected %q, got %q", name, out)
+		}
+	}
+}
+
+// TestWriteScriptRerun deletes a file and copies a new version under its
+// name, as keeping one version of a conflict does, and runs the script twice.
+func TestWriteScriptRerun(t *testing.T) {
+	needShell(t)
+	origin, copy := m.Root(t.TempDir()), m.Root(t.TempDir())
+	odd := "it's \"odd\" $HOME\nline.txt"
+	renamed := "sub dir/re'named.txt"
+	files := map[string]string{
+		filepath.Join(origin.String(), "x.txt"): "new",
+		filepath.Join(copy.String(), "x.txt"):   "old",
+		filepath.Join(copy.String(), odd):       "odd",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plan := m.Plan{
+		Roots: []m.Root{origin, copy},
+		Operations: []m.Operation{
+			{Op: "delete", From: planId(copy, "x.txt"), Hash: contentHash("old")},
+			{Op: "copy", From: planId(origin, "x.txt"), To: []m.PlanId{planId(copy, "x.txt")}, Hash: contentHash("new"), ModTime: time.Unix(1e9, 0)},
+			{Op: "rename", From: planId(copy, odd), To: []m.PlanId{planId(copy, renamed)}, Hash: contentHash("odd")},
+		},
+	}
+	script := &bytes.Buffer{}
+	if err := WriteScript(script, plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script.String(), quote(copyTempPath(m.Id{Root: copy, Name: m.Path("x.txt").ParentName()}))) {
+		t.Errorf("expected the copy to go through the hidden temporary file:\n%s", script)
+	}
+	path := filepath.Join(t.TempDir(), "plan.sh")
+	if err := os.WriteFile(path, script.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		if out, err := exec.Command("sh", path).CombinedOutput(); err != nil {
+			t.Fatalf("run %d: %v\n%s", run, err, out)
+		}
+		content, err := os.ReadFile(filepath.Join(copy.String(), "x.txt"))
+		if err != nil || string(content) != "new" {
+			t.Errorf("run %d: expected the new version, got %q, %v", run, content, err)
+		}
+		if _, err := os.Stat(filepath.Join(copy.String(), renamed)); err != nil {
+			t.Errorf("run %d: %v", run, err)
+		}
+		if _, err := os.Stat(filepath.Join(copy.String(), odd)); !os.IsNotExist(err) {
+			t.Errorf("run %d: expected %q to be renamed", run, odd)
+		}
+	}
+}
+
+func TestWriteScriptAlgorithm(t *testing.T) {
+	root := m.Root(t.TempDir())
+	plan := m.Plan{
+		Roots:      []m.Root{root},
+		Operations: []m.Operation{{Op: "delete", From: planId(root, "a.txt"), Hash: "dualcrc:AAAAAAAAAAA"}},
+	}
+	err := WriteScript(&bytes.Buffer{}, plan)
+	if err == nil || !strings.Contains(err.Error(), "dualcrc") {
+		t.Errorf("expected an error naming the algorithm, got %v", err)
+	}
+}
+
+func TestWriteScriptMove(t *testing.T) {
+	needShell(t)
+	for _, content := range []string{"y", "changed"} {
+		root := m.Root(t.TempDir())
+		testFile(t, root, "a/y.txt", content)
+		plan := m.Plan{
+			Roots: []m.Root{root},
+			Operations: []m.Operation{{
+				Op: "move", From: planId(root, "a"), To: []m.PlanId{planId(root, "b")},
+				Files: []m.PlanFile{{Name: "a/y.txt", Hash: contentHash("y")}},
+			}},
+		}
+		script := &bytes.Buffer{}
+		if err := WriteScript(script, plan); err != nil {
+			t.Fatal(err)
+		}
+		err := exec.Command("sh", "-c", script.String()).Run()
+		_, statErr := os.Stat(filepath.Join(root.String(), "b", "y.txt"))
+		if moved := err == nil && statErr == nil; moved != (content == "y") {
+			t.Errorf("%s: expected the folder to move only with the planned content, got %v, %v", content, err, statErr)
+		}
+	}
+}
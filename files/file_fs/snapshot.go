@@ -0,0 +1,239 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func (fs *fileFs) LoadSnapshot(roots []m.Root) {
+	go func() {
+		files, err := readSnapshot(existingPath(archiveSetPaths("snapshots", roots)), fs.algorithm)
+		if err != nil && !os.IsNotExist(err) {
+			fs.events.Push(m.Error{Error: err})
+		}
+		accepted, err := readAccepted(existingPath(archiveSetPaths("accepted", roots)), roots, fs.algorithm)
+		if err != nil && !os.IsNotExist(err) {
+			fs.events.Push(m.Error{Error: err})
+		}
+		interrupted, err := interruptedCopies(roots)
+		if err != nil && !os.IsNotExist(err) {
+			fs.events.Push(m.Error{Error: err})
+		}
+		journal, err := ReadJournal(roots)
+		if err != nil && !os.IsNotExist(err) {
+			fs.events.Push(m.Error{Error: err})
+		}
+		fs.events.Push(m.SnapshotLoaded{Files: files, Accepted: accepted, Interrupted: interrupted, Journal: journal})
+	}()
+}
+
+func (fs *fileFs) storeSnapshot(cmd m.StoreSnapshot) {
+	err := writeSnapshot(archiveSetPaths("snapshots", cmd.Roots)[0], cmd.Files)
+	if err != nil {
+		fs.events.Push(m.Error{Error: err})
+	}
+}
+
+func (fs *fileFs) storeAccepted(cmd m.StoreAccepted) {
+	err := writeAccepted(archiveSetPaths("accepted", cmd.Roots)[0], cmd.Files)
+	if err != nil {
+		fs.events.Push(m.Error{Error: err})
+	}
+}
+
+// DataDir is where arc keeps its own state outside of the archives.
+func DataDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "arc")
+}
+
+// archiveSetPaths are the files of the given kind for the set of roots
+// synced together, the current one first. Initialized roots are known by
+// their identity; the file keyed by the paths of the roots comes next.
+func archiveSetPaths(kind string, roots []m.Root) []string {
+	byKey := make([]string, len(roots))
+	byPath := make([]string, len(roots))
+	for i, root := range roots {
+		byKey[i] = rootKey(root)
+		byPath[i] = root.String()
+	}
+	result := []string{archiveSetPath(kind, byKey)}
+	if !slices.Equal(byKey, byPath) {
+		result = append(result, archiveSetPath(kind, byPath))
+	}
+	return result
+}
+
+func archiveSetPath(kind string, names []string) string {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	key := sha256.Sum256([]byte(strings.Join(names, "\n")))
+	return filepath.Join(DataDir(), kind, base64.RawURLEncoding.EncodeToString(key[:])+".csv")
+}
+
+// existingPath returns the first of the paths that exists, or else the first one.
+func existingPath(paths []string) string {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return paths[0]
+}
+
+// readSnapshot skips the files hashed by another algorithm, as their hashes
+// can't be compared to the hashes of the scan. Files without an Algorithm
+// column are known by the tag of their hash.
+func readSnapshot(path string, algorithm HashAlgorithm) ([]m.SnapshotFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	result := make([]m.SnapshotFile, 0, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) != 4 && len(record) != 5 {
+			continue
+		}
+		size, er1 := strconv.ParseUint(record[1], 10, 64)
+		modTime, er2 := time.Parse(time.RFC3339Nano, record[2])
+		if er1 != nil || er2 != nil || record[3] == "" || !hashedBy(record, 4, algorithm) {
+			continue
+		}
+		result = append(result, m.SnapshotFile{
+			Name:    m.Path(record[0]).ParentName(),
+			Hash:    m.Hash(record[3]),
+			Size:    size,
+			ModTime: modTime.UTC(),
+		})
+	}
+	return result, nil
+}
+
+func writeSnapshot(path string, files []m.SnapshotFile) error {
+	records := make([][]string, 1, len(files)+1)
+	records[0] = []string{"Name", "Size", "ModTime", "Hash", "Algorithm"}
+	for _, file := range files {
+		records = append(records, []string{
+			norm.NFC.String(file.Name.String()),
+			fmt.Sprint(file.Size),
+			file.ModTime.UTC().Format(time.RFC3339Nano),
+			file.Hash.String(),
+			algorithmName(file.Hash),
+		})
+	}
+	return writeCSV(path, records)
+}
+
+// readAccepted maps the roots recorded by their identity or path to the
+// roots, skipping the files hashed by another algorithm.
+func readAccepted(path string, roots []m.Root, algorithm HashAlgorithm) ([]m.AcceptedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	byKey := rootsByKey(roots)
+	result := make([]m.AcceptedFile, 0, len(records)-1)
+	for _, record := range records[1:] {
+		if (len(record) != 3 && len(record) != 4) || record[2] == "" || !hashedBy(record, 3, algorithm) {
+			continue
+		}
+		root, ok := byKey[record[0]]
+		if !ok {
+			continue
+		}
+		result = append(result, m.AcceptedFile{
+			Id:   m.Id{Root: root, Name: m.Path(record[1]).ParentName()},
+			Hash: m.Hash(record[2]),
+		})
+	}
+	return result, nil
+}
+
+func writeAccepted(path string, files []m.AcceptedFile) error {
+	records := make([][]string, 1, len(files)+1)
+	records[0] = []string{"Root", "Name", "Hash", "Algorithm"}
+	keys := map[m.Root]string{}
+	for _, file := range files {
+		key, ok := keys[file.Root]
+		if !ok {
+			key = rootKey(file.Root)
+			keys[file.Root] = key
+		}
+		records = append(records, []string{
+			key,
+			norm.NFC.String(file.Name.String()),
+			file.Hash.String(),
+			algorithmName(file.Hash),
+		})
+	}
+	return writeCSV(path, records)
+}
+
+// hashedBy tells if the record was hashed by the algorithm, by its Algorithm
+// column or else by the tag of its hash in the column before.
+func hashedBy(record []string, column int, algorithm HashAlgorithm) bool {
+	name := algorithmName(m.Hash(record[column-1]))
+	if column < len(record) {
+		name = record[column]
+	}
+	return name == algorithm.Name()
+}
+
+// writeCSV replaces the file atomically, so readers see either the old or the new content.
+func writeCSV(path string, records [][]string) error {
+	return writeAtomic(path, func(file io.Writer) error {
+		return csv.NewWriter(file).WriteAll(records)
+	})
+}
+
+func writeAtomic(path string, write func(file io.Writer) error) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
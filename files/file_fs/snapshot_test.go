@@ -0,0 +1,61 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAlgorithm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.csv")
+	files := []m.SnapshotFile{
+		{Name: m.Path("a.txt").ParentName(), Hash: contentHash("a"), Size: 1, ModTime: time.Unix(1e9, 0).UTC()},
+		{Name: m.Path("b.txt").ParentName(), Hash: "dualcrc:AAAAAAAAAAA", Size: 1, ModTime: time.Unix(1e9, 0).UTC()},
+	}
+	if err := writeSnapshot(path, files); err != nil {
+		t.Fatal(err)
+	}
+	for _, algorithm := range HashAlgorithms {
+		read, err := readSnapshot(path, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read) != 1 || algorithmName(read[0].Hash) != algorithm.Name() {
+			t.Errorf("%s: expected only the file hashed by it, got %v", algorithm.Name(), read)
+		}
+	}
+}
+
+func TestAcceptedAlgorithm(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	root := m.Root(t.TempDir())
+	path := filepath.Join(t.TempDir(), "accepted.csv")
+	files := []m.AcceptedFile{
+		{Id: m.Id{Root: root, Name: m.Path("a.txt").ParentName()}, Hash: contentHash("a")},
+		{Id: m.Id{Root: root, Name: m.Path("b.txt").ParentName()}, Hash: "dualcrc:AAAAAAAAAAA"},
+	}
+	if err := writeAccepted(path, files); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readAccepted(path, []m.Root{root}, DualCRC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 1 || read[0] != files[1] {
+		t.Errorf("expected only %v, got %v", files[1], read)
+	}
+
+	// Files written before the Algorithm column are known by the tags of their hashes.
+	legacy := "Root,Name,Hash\n" + root.String() + ",a.txt," + contentHash("a").String() + "\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := readAccepted(path, []m.Root{root}, DualCRC); err != nil || len(read) != 0 {
+		t.Errorf("expected no dualcrc files, got %v, %v", read, err)
+	}
+	if read, err := readAccepted(path, []m.Root{root}, SHA256); err != nil || len(read) != 1 || read[0] != files[0] {
+		t.Errorf("expected %v, got %v, %v", files[0], read, err)
+	}
+}
@@ -0,0 +1,316 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	trashDirName   = ".arc-trash"
+	trashIndexName = ".index.csv"
+	batchLayout    = "20060102-150405"
+)
+
+// trashBatch is the folder of the trash holding the files deleted in one session.
+type trashBatch struct {
+	root    m.Root
+	name    string
+	deleted time.Time
+	files   []m.TrashedFile
+}
+
+func (b *trashBatch) path() string {
+	return filepath.Join(b.root.String(), trashDirName, b.name)
+}
+
+func (b *trashBatch) size() uint64 {
+	size := uint64(0)
+	for _, file := range b.files {
+		size += file.Size
+	}
+	return size
+}
+
+// remove removes the batch and the trash folder once it is empty.
+func (b *trashBatch) remove() error {
+	err := os.RemoveAll(b.path())
+	os.Remove(filepath.Dir(b.path()))
+	return err
+}
+
+// TrashPath is where the trashed file is kept.
+func TrashPath(file m.TrashedFile) string {
+	return filepath.Join(file.Root.String(), trashDirName, file.Batch, file.Name.String())
+}
+
+// trash moves the file into the trash batch of the session, mirroring its path.
+func (f *fileFs) trash(delete m.DeleteFile) (m.TrashedFile, error) {
+	info, err := os.Stat(delete.Id.String())
+	if err != nil {
+		return m.TrashedFile{}, err
+	}
+	batch := &trashBatch{root: delete.Id.Root, name: f.session}
+	for i := 1; ; i++ {
+		// Other errors are reported by creating the folder below.
+		_, err := os.Stat(filepath.Join(batch.path(), delete.Id.Name.String()))
+		if err != nil {
+			break
+		}
+		batch.name = fmt.Sprintf("%s.%d", f.session, i)
+	}
+	file := m.TrashedFile{
+		Id:      delete.Id,
+		Batch:   batch.name,
+		Hash:    delete.Hash,
+		Size:    uint64(info.Size()),
+		ModTime: info.ModTime(),
+	}
+	err = os.MkdirAll(filepath.Dir(TrashPath(file)), 0755)
+	if err != nil {
+		return file, err
+	}
+	batch.files, err = readTrashIndex(batch)
+	if err != nil && !os.IsNotExist(err) {
+		return file, err
+	}
+	err = os.Rename(delete.Id.String(), TrashPath(file))
+	if err != nil {
+		return file, err
+	}
+	batch.files = append(batch.files, file)
+	return file, writeTrashIndex(batch)
+}
+
+func ListTrash(roots []m.Root) ([]m.TrashedFile, error) {
+	result := []m.TrashedFile{}
+	for _, root := range roots {
+		batches, err := trashBatches(root)
+		if err != nil {
+			return result, err
+		}
+		for _, batch := range batches {
+			result = append(result, batch.files...)
+		}
+	}
+	slices.SortFunc(result, func(a, b m.TrashedFile) int {
+		if result := b.Deleted.Compare(a.Deleted); result != 0 {
+			return result
+		}
+		return strings.Compare(a.Id.String(), b.Id.String())
+	})
+	return result, nil
+}
+
+// RestoreTrashed moves the file back to where it was deleted from.
+func RestoreTrashed(file m.TrashedFile) error {
+	_, err := os.Stat(file.Id.String())
+	if err == nil {
+		return fmt.Errorf("cannot restore %q: the file exists", file.Id)
+	}
+	err = os.MkdirAll(filepath.Join(file.Root.String(), file.Path.String()), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(TrashPath(file), file.Id.String())
+	if err != nil {
+		return err
+	}
+	batch := &trashBatch{root: file.Root, name: file.Batch}
+	batch.files, err = readTrashIndex(batch)
+	if err != nil {
+		return err
+	}
+	batch.files = slices.DeleteFunc(batch.files, func(other m.TrashedFile) bool { return other.Name == file.Name })
+	if len(batch.files) == 0 {
+		return batch.remove()
+	}
+	return writeTrashIndex(batch)
+}
+
+// PurgeTrash removes the batches of the roots deleted before the time.
+func PurgeTrash(roots []m.Root, before time.Time) ([]m.TrashedFile, error) {
+	purged := []m.TrashedFile{}
+	for _, root := range roots {
+		batches, err := trashBatches(root)
+		if err != nil {
+			return purged, err
+		}
+		for _, batch := range batches {
+			if batch.deleted.Before(before) {
+				if err := batch.remove(); err != nil {
+					return purged, err
+				}
+				purged = append(purged, batch.files...)
+			}
+		}
+	}
+	return purged, nil
+}
+
+// EnforceRetention purges the oldest batches of the root beyond the retention.
+// The batches of the session are kept and don't count, so the files it just
+// deleted can always be restored.
+func EnforceRetention(root m.Root, retention m.Retention, session string) ([]m.TrashedFile, error) {
+	batches, err := trashBatches(root)
+	if err != nil || (retention.Days == 0 && retention.Bytes == 0) {
+		return nil, err
+	}
+	purged := []m.TrashedFile{}
+	limit := time.Now().AddDate(0, 0, -retention.Days)
+	total := uint64(0)
+	for _, batch := range batches {
+		if batch.name == session || strings.HasPrefix(batch.name, session+".") {
+			continue
+		}
+		total += batch.size()
+		if (retention.Days > 0 && batch.deleted.Before(limit)) || (retention.Bytes > 0 && total > retention.Bytes) {
+			if err := batch.remove(); err != nil {
+				return purged, err
+			}
+			purged = append(purged, batch.files...)
+		}
+	}
+	return purged, nil
+}
+
+// trashBatches lists the batches of the root, newest first.
+func trashBatches(root m.Root) ([]*trashBatch, error) {
+	entries, err := os.ReadDir(filepath.Join(root.String(), trashDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []*trashBatch{}
+	for _, entry := range entries {
+		stamp, _, _ := strings.Cut(entry.Name(), ".")
+		deleted, err := time.ParseInLocation(batchLayout, stamp, time.UTC)
+		if !entry.IsDir() || err != nil {
+			continue
+		}
+		batch := &trashBatch{root: root, name: entry.Name(), deleted: deleted}
+		batch.files, err = readTrashIndex(batch)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, batch)
+	}
+	slices.SortFunc(result, func(a, b *trashBatch) int {
+		if result := b.deleted.Compare(a.deleted); result != 0 {
+			return result
+		}
+		return strings.Compare(b.name, a.name)
+	})
+	return result, nil
+}
+
+func readTrashIndex(batch *trashBatch) ([]m.TrashedFile, error) {
+	file, err := os.Open(filepath.Join(batch.path(), trashIndexName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	result := make([]m.TrashedFile, 0, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) != 4 {
+			continue
+		}
+		size, er1 := strconv.ParseUint(record[2], 10, 64)
+		modTime, er2 := time.Parse(time.RFC3339Nano, record[3])
+		if er1 != nil || er2 != nil {
+			continue
+		}
+		result = append(result, m.TrashedFile{
+			Id:      m.Id{Root: batch.root, Name: m.Path(record[0]).ParentName()},
+			Batch:   batch.name,
+			Hash:    m.Hash(record[1]),
+			Size:    size,
+			ModTime: modTime,
+			Deleted: batch.deleted,
+		})
+	}
+	return result, nil
+}
+
+func writeTrashIndex(batch *trashBatch) error {
+	records := make([][]string, 1, len(batch.files)+1)
+	records[0] = []string{"Name", "Hash", "Size", "ModTime"}
+	for _, file := range batch.files {
+		records = append(records, []string{
+			norm.NFC.String(file.Name.String()),
+			file.Hash.String(),
+			fmt.Sprint(file.Size),
+			file.ModTime.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	return writeCSV(filepath.Join(batch.path(), trashIndexName), records)
+}
+
+func (f *fileFs) listTrash(roots []m.Root) {
+	files, err := ListTrash(roots)
+	if err != nil {
+		f.events.Push(m.Error{Error: err})
+	}
+	f.events.Push(m.TrashListed{Files: files})
+}
+
+func (f *fileFs) restoreFile(restore m.RestoreFile) {
+	err := RestoreTrashed(restore.File)
+	if err != nil {
+		f.events.Push(m.Error{Id: restore.File.Id, Error: err})
+	} else {
+		if info, err := os.Stat(restore.File.Id.String()); err == nil {
+			f.addHashes(restore.File.Root, newHashEntry(restore.File.Id, info, restore.File.Hash))
+		}
+		from := m.PlanId{Root: restore.File.Root, Name: restore.File.Name.String()}
+		f.record(m.JournalEntry{
+			Operation: m.Operation{Op: "restore", From: from, Hash: restore.File.Hash, Size: restore.File.Size, ModTime: restore.File.ModTime},
+			Trash:     restore.File.Batch,
+		})
+		f.events.Push(m.FileRestored(restore))
+	}
+	f.listTrash([]m.Root{restore.File.Root})
+}
+
+func (f *fileFs) purgeTrash(purge m.PurgeTrash) {
+	purged, err := PurgeTrash(purge.Roots, purge.Before)
+	f.recordPurged(purged)
+	if err != nil {
+		f.events.Push(m.Error{Error: err})
+	}
+	f.listTrash(purge.Roots)
+}
+
+// recordPurged journals every purged batch with the size of its files.
+func (f *fileFs) recordPurged(purged []m.TrashedFile) {
+	entries := []m.JournalEntry{}
+	for _, file := range purged {
+		idx := slices.IndexFunc(entries, func(entry m.JournalEntry) bool {
+			return entry.From.Root == file.Root && entry.Trash == file.Batch
+		})
+		if idx < 0 {
+			idx = len(entries)
+			entries = append(entries, m.JournalEntry{Operation: m.Operation{Op: "purge", From: m.PlanId{Root: file.Root}}, Trash: file.Batch})
+		}
+		entries[idx].Size += file.Size
+	}
+	for _, entry := range entries {
+		f.record(entry)
+	}
+}
@@ -0,0 +1,197 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func sessionAt(deleted time.Time) *fileFs {
+	return &fileFs{session: deleted.UTC().Format(batchLayout)}
+}
+
+func trashFile(t *testing.T, f *fileFs, root m.Root, name, content string) m.TrashedFile {
+	t.Helper()
+	entry := testFile(t, root, name, content)
+	file, err := f.trash(m.DeleteFile{Id: entry.id, Hash: contentHash(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func batchNames(t *testing.T, root m.Root) []string {
+	t.Helper()
+	batches, err := trashBatches(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, batch := range batches {
+		names = append(names, batch.name)
+	}
+	return names
+}
+
+func TestTrashNameClash(t *testing.T) {
+	root := m.Root(t.TempDir())
+	f := sessionAt(time.Now())
+	first := trashFile(t, f, root, "dir/a.txt", "first")
+	second := trashFile(t, f, root, "dir/a.txt", "second")
+	other := trashFile(t, f, root, "b.txt", "other")
+
+	if first.Batch != f.session || second.Batch != f.session+".1" || other.Batch != f.session {
+		t.Fatalf("unexpected batches %q, %q, %q", first.Batch, second.Batch, other.Batch)
+	}
+	for _, file := range []m.TrashedFile{first, second, other} {
+		if _, err := os.Stat(TrashPath(file)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	files, err := ListTrash([]m.Root{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 trashed files, got %v", files)
+	}
+	for _, file := range []m.TrashedFile{first, second, other} {
+		found := slices.ContainsFunc(files, func(listed m.TrashedFile) bool {
+			return listed.Id == file.Id && listed.Batch == file.Batch && listed.Hash == file.Hash &&
+				listed.Size == file.Size && listed.ModTime.Equal(file.ModTime)
+		})
+		if !found {
+			t.Errorf("%v is not in the index: %v", file, files)
+		}
+	}
+}
+
+func TestRestoreTrashed(t *testing.T) {
+	root := m.Root(t.TempDir())
+	f := sessionAt(time.Now())
+	file := trashFile(t, f, root, "dir/a.txt", "aaaa")
+	os.Remove(filepath.Join(root.String(), "dir"))
+
+	if err := RestoreTrashed(file); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file.Id.String())
+	if err != nil || string(content) != "aaaa" {
+		t.Errorf("expected the restored file, got %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(root.String(), trashDirName)); !os.IsNotExist(err) {
+		t.Errorf("expected the empty trash to be removed, got %v", err)
+	}
+}
+
+func TestRestoreTrashedExisting(t *testing.T) {
+	root := m.Root(t.TempDir())
+	f := sessionAt(time.Now())
+	file := trashFile(t, f, root, "a.txt", "old")
+	testFile(t, root, "a.txt", "new")
+
+	if err := RestoreTrashed(file); err == nil {
+		t.Fatal("expected an error restoring onto an existing file")
+	}
+	content, err := os.ReadFile(file.Id.String())
+	if err != nil || string(content) != "new" {
+		t.Errorf("expected the existing file to be kept, got %q, %v", content, err)
+	}
+	if _, err := os.Stat(TrashPath(file)); err != nil {
+		t.Errorf("expected the trashed file to be kept: %v", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	root := m.Root(t.TempDir())
+	old := sessionAt(time.Now().AddDate(0, 0, -10))
+	current := sessionAt(time.Now())
+	purgedFile := trashFile(t, old, root, "a.txt", "aaaa")
+	trashFile(t, current, root, "b.txt", "bbbb")
+
+	purged, err := PurgeTrash([]m.Root{root}, time.Now().AddDate(0, 0, -5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Id != purgedFile.Id {
+		t.Errorf("expected %v to be purged, got %v", purgedFile.Id, purged)
+	}
+	if names := batchNames(t, root); !slices.Equal(names, []string{current.session}) {
+		t.Errorf("expected only %q to be left, got %v", current.session, names)
+	}
+}
+
+func TestEnforceRetentionDays(t *testing.T) {
+	root := m.Root(t.TempDir())
+	old := sessionAt(time.Now().AddDate(0, 0, -10))
+	recent := sessionAt(time.Now().AddDate(0, 0, -2))
+	trashFile(t, old, root, "a.txt", "aaaa")
+	trashFile(t, recent, root, "b.txt", "bbbb")
+
+	purged, err := EnforceRetention(root, m.Retention{Days: 5}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Batch != old.session {
+		t.Errorf("expected the batch %q to be purged, got %v", old.session, purged)
+	}
+	if names := batchNames(t, root); !slices.Equal(names, []string{recent.session}) {
+		t.Errorf("expected only %q to be left, got %v", recent.session, names)
+	}
+}
+
+func TestEnforceRetentionBytes(t *testing.T) {
+	root := m.Root(t.TempDir())
+	oldest := sessionAt(time.Now().AddDate(0, 0, -3))
+	older := sessionAt(time.Now().AddDate(0, 0, -2))
+	current := sessionAt(time.Now())
+	trashFile(t, oldest, root, "a.txt", "aaaa")
+	trashFile(t, older, root, "b.txt", "bbbb")
+	trashFile(t, current, root, "c.txt", "cccccccc")
+	trashFile(t, current, root, "c.txt", "cccccccc")
+
+	// The session's batches are larger than the limit and are kept all the same.
+	purged, err := EnforceRetention(root, m.Retention{Bytes: 4}, current.session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Batch != oldest.session {
+		t.Errorf("expected the batch %q to be purged, got %v", oldest.session, purged)
+	}
+	expected := []string{current.session + ".1", current.session, older.session}
+	if names := batchNames(t, root); !slices.Equal(names, expected) {
+		t.Errorf("expected %v to be left, got %v", expected, names)
+	}
+}
+
+func TestTrashJournal(t *testing.T) {
+	f := newTestFs(t)
+	root := m.Root(t.TempDir())
+	restored := trashFile(t, f, root, "a.txt", "aaaa")
+	old := sessionAt(time.Now().AddDate(0, 0, -10))
+	purged := trashFile(t, old, root, "b.txt", "bbbbbb")
+
+	f.restoreFile(m.RestoreFile{File: restored})
+	f.purgeTrash(m.PurgeTrash{Roots: []m.Root{root}, Before: time.Now().AddDate(0, 0, -5)})
+
+	entries, err := ReadJournal([]m.Root{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected a restore and a purge, got %v", entries)
+	}
+	if entry := entries[0]; entry.Op != "restore" || entry.From.Id() != restored.Id || entry.Trash != restored.Batch {
+		t.Errorf("expected the restore of %v, got %v", restored.Id, entry)
+	}
+	if entry := entries[1]; entry.Op != "purge" || entry.Trash != purged.Batch || entry.Size != purged.Size {
+		t.Errorf("expected the purge of %q, got %v", purged.Batch, entry)
+	}
+	if undoable := m.Undoable(entries); len(undoable) != 1 || undoable[0].Op != "restore" {
+		t.Errorf("expected only the restore to be undoable, got %v", undoable)
+	}
+}
@@ -0,0 +1,88 @@
+package file_fs
+
+import (
+	m "arc/model"
+	"cmp"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+)
+
+// StaleHash is a stored hash that no longer describes its file.
+type StaleHash struct {
+	Id     m.Id
+	Reason string
+}
+
+// VerifyHashStore drops the entries of the hash store of the root whose
+// files are gone or changed. Rehash also reads every file, to find content
+// that changed while its size and modification time stayed the same.
+func VerifyHashStore(root m.Root, catalog, rehash bool) ([]StaleHash, error) {
+	store, err := openHashStore(root, catalog)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]hashEntry, 0, len(store.entries))
+	for _, entry := range store.entries {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b hashEntry) int {
+		return cmp.Compare(a.id.Name.String(), b.id.Name.String())
+	})
+
+	stale := []StaleHash{}
+	errs := []error{}
+	for _, entry := range entries {
+		info, err := os.Lstat(entry.id.String())
+		if errors.Is(err, fs.ErrNotExist) {
+			stale = append(stale, StaleHash{Id: entry.id, Reason: "missing"})
+			store.remove(entry)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		file := newHashEntry(entry.id, info, entry.hash)
+		if !info.Mode().IsRegular() || !entry.describes(file) {
+			stale = append(stale, StaleHash{Id: entry.id, Reason: "changed"})
+			store.remove(entry)
+			continue
+		}
+		algorithm := algorithmOf(entry.hash)
+		if !rehash || algorithm == nil {
+			continue
+		}
+		hash, err := hashPath(entry.id.String(), algorithm)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if hash != entry.hash {
+			stale = append(stale, StaleHash{Id: entry.id, Reason: "content"})
+			file.hash = hash
+		}
+		store.remove(entry)
+		store.put(file)
+	}
+	store.changed = true
+	if err := store.save(); err != nil {
+		errs = append(errs, err)
+	}
+	return stale, errors.Join(errs...)
+}
+
+func hashPath(path string, algorithm HashAlgorithm) (m.Hash, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := algorithm.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return encodeHash(algorithm, hash.Sum(nil)), nil
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"math/rand"
 	"path/filepath"
+	"slices"
 	"sort"
 	"time"
 )
@@ -39,6 +40,10 @@ func (fs *mockFs) Scan(root m.Root) {
 	go s.scanArchive()
 }
 
+func (fs *mockFs) LoadSnapshot(roots []m.Root) {
+	fs.eventStream.Push(m.SnapshotLoaded{Files: snapshot, Accepted: accepted})
+}
+
 func (s *mockFs) Send(cmd m.FileCommand) {
 	s.commands.Push(cmd)
 }
@@ -56,11 +61,21 @@ func (fs *mockFs) handleCommand(cmd m.FileCommand) {
 	log.Printf("mock: cmd: %T: %v", cmd, cmd)
 	switch cmd := cmd.(type) {
 	case m.DeleteFile:
+		file := m.TrashedFile{Id: cmd.Id, Batch: "mock", Hash: cmd.Hash, Deleted: time.Now()}
+		for _, meta := range metas[cmd.Id.Root] {
+			if meta.Id == cmd.Id {
+				file.Size, file.ModTime = meta.Size, meta.ModTime
+			}
+		}
+		trash = append(trash, file)
 		fs.eventStream.Push(m.FileDeleted(cmd))
 
 	case m.RenameFile:
 		fs.eventStream.Push(m.FileRenamed(cmd))
 
+	case m.RenameFolder:
+		fs.eventStream.Push(m.FolderRenamed(cmd))
+
 	case m.CopyFile:
 		for _, meta := range metas[cmd.From.Root] {
 			if meta.Id.Name == cmd.From.Name {
@@ -78,6 +93,39 @@ func (fs *mockFs) handleCommand(cmd m.FileCommand) {
 			}
 		}
 		fs.eventStream.Push(m.FileCopied(cmd))
+
+	case m.StoreSnapshot:
+		snapshot = cmd.Files
+
+	case m.StoreAccepted:
+		accepted = cmd.Files
+
+	case m.StorePlan:
+		fs.eventStream.Push(m.PlanStored{Path: cmd.Path})
+
+	case m.ListTrash:
+		fs.eventStream.Push(m.TrashListed{Files: slices.Clone(trash)})
+
+	case m.RestoreFile:
+		trash = slices.DeleteFunc(trash, func(file m.TrashedFile) bool { return file.Id == cmd.File.Id })
+		fs.eventStream.Push(m.FileRestored(cmd))
+		fs.eventStream.Push(m.TrashListed{Files: slices.Clone(trash)})
+
+	case m.PurgeTrash:
+		trash = slices.DeleteFunc(trash, func(file m.TrashedFile) bool { return file.Deleted.Before(cmd.Before) })
+		fs.eventStream.Push(m.TrashListed{Files: slices.Clone(trash)})
+
+	case m.HashFiles:
+		for _, id := range cmd.Ids {
+			for _, meta := range metas[id.Root] {
+				if meta.Id == id && cmd.Partial {
+					fs.eventStream.Push(m.PartialHashed{Id: id, Hash: meta.Hash})
+				} else if meta.Id == id {
+					fs.eventStream.Push(m.FileHashed{Id: id, Hash: meta.Hash})
+				}
+			}
+		}
+		fs.eventStream.Push(m.FilesHashed{Partial: cmd.Partial})
 	}
 }
 
@@ -196,6 +244,10 @@ func init() {
 	}
 }
 
+var snapshot []m.SnapshotFile
+var accepted []m.AcceptedFile
+var trash []m.TrashedFile
+
 var metas = map[m.Root][]*fileMeta{}
 var metaMap = map[m.Root]map[string]m.Hash{
 	"origin": {
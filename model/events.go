@@ -20,6 +20,14 @@ type ArchiveScanned struct {
 
 func (ArchiveScanned) event() {}
 
+// ArchiveIdentified reports the identity of an initialized root.
+type ArchiveIdentified struct {
+	Root
+	Identity
+}
+
+func (ArchiveIdentified) event() {}
+
 type ArchiveHashed struct {
 	Root
 }
@@ -33,10 +41,34 @@ type FileHashed struct {
 
 func (FileHashed) event() {}
 
+// PartialHashed carries the hash of the head, middle and tail of a file.
+type PartialHashed struct {
+	Id
+	Hash
+}
+
+func (PartialHashed) event() {}
+
+// FilesHashed follows the hashes of a HashFiles command.
+type FilesHashed struct {
+	Partial bool
+}
+
+func (FilesHashed) event() {}
+
 func (f FileHashed) String() string {
 	return fmt.Sprintf("FileHashed: Id: %q, Hash: %q", f.Id, f.Hash)
 }
 
+type SnapshotLoaded struct {
+	Files       []SnapshotFile
+	Accepted    []AcceptedFile
+	Interrupted []InterruptedCopy
+	Journal     []JournalEntry
+}
+
+func (SnapshotLoaded) event() {}
+
 type FileDeleted DeleteFile
 
 func (FileDeleted) event() {}
@@ -45,6 +77,16 @@ func (h FileDeleted) String() string {
 	return DeleteFile(h).String()
 }
 
+// DeleteFailed ends a delete that did not move the file to the trash;
+// Kept tells that the file is still on disk as Meta describes it.
+type DeleteFailed struct {
+	Meta
+	Hash Hash
+	Kept bool
+}
+
+func (DeleteFailed) event() {}
+
 type FileRenamed RenameFile
 
 func (FileRenamed) event() {}
@@ -53,6 +95,14 @@ func (h FileRenamed) String() string {
 	return RenameFile(h).String()
 }
 
+type FolderRenamed RenameFolder
+
+func (FolderRenamed) event() {}
+
+func (h FolderRenamed) String() string {
+	return RenameFolder(h).String()
+}
+
 type FileCopied CopyFile
 
 func (FileCopied) event() {}
@@ -87,6 +137,45 @@ type Error struct {
 
 func (Error) event() {}
 
+// HashMismatch reports a copy whose content differs from its source.
+type HashMismatch struct {
+	Id       Id
+	Expected Hash
+	Actual   Hash
+}
+
+func (HashMismatch) event() {}
+
+func (e HashMismatch) String() string {
+	return fmt.Sprintf("HashMismatch: Id: %q, expected: %q, actual: %q", e.Id, e.Expected, e.Actual)
+}
+
+type TrashListed struct {
+	Files []TrashedFile
+}
+
+func (TrashListed) event() {}
+
+type FileRestored RestoreFile
+
+func (FileRestored) event() {}
+
+type Journaled struct {
+	Entry JournalEntry
+}
+
+func (Journaled) event() {}
+
+type Undo struct {
+	Session bool
+}
+
+func (Undo) event() {}
+
+type ShowTrash struct{}
+
+func (ShowTrash) event() {}
+
 type ScreenSize struct {
 	Width, Height int
 }
@@ -139,7 +228,19 @@ type KeepAll struct{}
 
 func (KeepAll) event() {}
 
-type Tab struct{}
+type ShowConflicts struct{}
+
+func (ShowConflicts) event() {}
+
+type Cancel struct{}
+
+func (Cancel) event() {}
+
+type CyclePolicy struct{}
+
+func (CyclePolicy) event() {}
+
+type Tab struct{ Backward bool }
 
 func (Tab) event() {}
 
@@ -147,6 +248,22 @@ type Delete struct{}
 
 func (Delete) event() {}
 
+type ToggleDryRun struct{}
+
+func (ToggleDryRun) event() {}
+
+type ToggleOperation struct{}
+
+func (ToggleOperation) event() {}
+
+type SavePlan struct{}
+
+func (SavePlan) event() {}
+
+type PlanStored struct{ Path string }
+
+func (PlanStored) event() {}
+
 type Scroll struct {
 	Command any
 	Lines   int
@@ -2,10 +2,12 @@ package model
 
 import (
 	"fmt"
+	"time"
 )
 
 type FS interface {
 	Scan(root Root)
+	LoadSnapshot(roots []Root)
 	Send(cmd FileCommand)
 }
 
@@ -36,6 +38,18 @@ func (r RenameFile) String() string {
 	return fmt.Sprintf("RenameFile: From: %q, To: %q, hash: %q", r.From, r.To, r.Hash)
 }
 
+type RenameFolder struct {
+	Root Root
+	From Path
+	To   Path
+}
+
+func (RenameFolder) cmd() {}
+
+func (r RenameFolder) String() string {
+	return fmt.Sprintf("RenameFolder: Root: %q, From: %q, To: %q", r.Root, r.From, r.To)
+}
+
 type CopyFile struct {
 	Hash Hash // TODO Need it?
 	From Id
@@ -47,3 +61,76 @@ func (CopyFile) cmd() {}
 func (c CopyFile) String() string {
 	return fmt.Sprintf("CopyFile: From: %q, To: %v, hash: %q", c.From, c.To, c.Hash)
 }
+
+type StoreSnapshot struct {
+	Roots []Root
+	Files []SnapshotFile
+}
+
+func (StoreSnapshot) cmd() {}
+
+func (s StoreSnapshot) String() string {
+	return fmt.Sprintf("StoreSnapshot: Roots: %q, files: %d", s.Roots, len(s.Files))
+}
+
+type StoreAccepted struct {
+	Roots []Root
+	Files []AcceptedFile
+}
+
+func (StoreAccepted) cmd() {}
+
+func (s StoreAccepted) String() string {
+	return fmt.Sprintf("StoreAccepted: Roots: %q, files: %d", s.Roots, len(s.Files))
+}
+
+type StorePlan struct {
+	Path string
+	Plan Plan
+}
+
+func (StorePlan) cmd() {}
+
+func (s StorePlan) String() string {
+	return fmt.Sprintf("StorePlan: Path: %q, operations: %d", s.Path, len(s.Plan.Operations))
+}
+
+type ListTrash struct {
+	Roots []Root
+}
+
+func (ListTrash) cmd() {}
+
+type RestoreFile struct {
+	File TrashedFile
+}
+
+func (RestoreFile) cmd() {}
+
+func (r RestoreFile) String() string {
+	return fmt.Sprintf("RestoreFile: Id: %q, batch: %q", r.File.Id, r.File.Batch)
+}
+
+// PurgeTrash removes the files deleted before the time from the trash of the roots.
+type PurgeTrash struct {
+	Roots  []Root
+	Before time.Time
+}
+
+func (PurgeTrash) cmd() {}
+
+// MarkUndone journals that the entries were undone.
+type MarkUndone struct {
+	Ids []string
+}
+
+func (MarkUndone) cmd() {}
+
+// HashFiles hashes the files a lazy scan left without hashes; a partial hash
+// reads only the head, the middle and the tail of large files.
+type HashFiles struct {
+	Ids     []Id
+	Partial bool
+}
+
+func (HashFiles) cmd() {}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Identity names an archive independent of where it is mounted.
+type Identity struct {
+	Id      string    `json:"id"`
+	Label   string    `json:"label"`
+	Created time.Time `json:"created"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// JournalEntry records a file operation arc applied, with the file before
+// and after it, or the undo of earlier entries.
+type JournalEntry struct {
+	Id      string    `json:"id"`
+	Session string    `json:"session"`
+	Time    time.Time `json:"time"`
+	Operation
+	Trash  string   `json:"trash,omitempty"`
+	Undoes []string `json:"undoes,omitempty"`
+	// FromKey and ToKeys identify the roots, so the entry is found after a root moved.
+	FromKey string   `json:"from_key,omitempty"`
+	ToKeys  []string `json:"to_keys,omitempty"`
+}
+
+// NewUndo records that the entries were undone.
+func NewUndo(ids []string) JournalEntry {
+	return JournalEntry{Operation: Operation{Op: "undo"}, Undoes: ids}
+}
+
+// Undoable returns the entries that can still be undone, oldest first.
+// Purged files are gone for good.
+func Undoable(entries []JournalEntry) []JournalEntry {
+	undone := map[string]bool{}
+	for _, entry := range entries {
+		for _, id := range entry.Undoes {
+			undone[id] = true
+		}
+	}
+	result := []JournalEntry{}
+	for _, entry := range entries {
+		if entry.Op != "undo" && entry.Op != "purge" && !undone[entry.Id] {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -10,6 +10,7 @@ type File struct {
 	Meta
 	State
 	Hash
+	Divergence
 	Counts   []int
 	Progress uint64
 }
@@ -89,6 +90,48 @@ func (m *Meta) String() string {
 		m.Root, m.Path, m.Base, m.Size, m.ModTime.Format(time.DateTime))
 }
 
+// SnapshotFile is a file as it was in every root after the last successful sync.
+type SnapshotFile struct {
+	Name
+	Hash
+	Size    uint64
+	ModTime time.Time
+}
+
+// AcceptedFile is a copy of an intentionally divergent file.
+type AcceptedFile struct {
+	Id
+	Hash
+}
+
+// InterruptedCopy is a copy that stopped before it was committed.
+// The first Committed bytes of the partial file are on disk.
+type InterruptedCopy struct {
+	From      Id
+	To        Id
+	Hash      Hash
+	Committed uint64
+	// FromKey and ToKey identify the roots, so the copy is found after a root moved.
+	FromKey string
+	ToKey   string
+}
+
+// TrashedFile is a deleted file kept in the trash of its root.
+type TrashedFile struct {
+	Id
+	Batch   string
+	Hash    Hash
+	Size    uint64
+	ModTime time.Time
+	Deleted time.Time
+}
+
+// Retention limits the trash of every root; zero means no limit.
+type Retention struct {
+	Days  int
+	Bytes uint64
+}
+
 type State int
 
 const (
@@ -98,6 +141,7 @@ const (
 	Hashed
 	Pending
 	Copying
+	Unique // not hashed: no other file has its size or partial hash
 	Divergent
 )
 
@@ -115,6 +159,8 @@ func (s State) String() string {
 		return "Pending"
 	case Copying:
 		return "Copying"
+	case Unique:
+		return "Unique"
 	case Divergent:
 		return "Divergent"
 	}
@@ -128,6 +174,35 @@ func (s State) Merge(other State) State {
 	return s
 }
 
+type Divergence int
+
+const (
+	NoDivergence Divergence = iota
+	Missing
+	Renamed
+	Moved
+	Changed
+	Duplicated
+)
+
+func (d Divergence) String() string {
+	switch d {
+	case NoDivergence:
+		return ""
+	case Missing:
+		return "Missing"
+	case Renamed:
+		return "Renamed"
+	case Moved:
+		return "Moved"
+	case Changed:
+		return "Changed"
+	case Duplicated:
+		return "Duplicate"
+	}
+	return "UNKNOWN DIVERGENCE"
+}
+
 type SortColumn int
 
 const (
@@ -147,3 +222,34 @@ func (c SortColumn) String() string {
 	}
 	return "Illegal Sort Solumn"
 }
+
+type Policy int
+
+const (
+	OriginWins Policy = iota
+	NewestWins
+	MajorityWins
+)
+
+var Policies = []Policy{OriginWins, NewestWins, MajorityWins}
+
+func (p Policy) String() string {
+	switch p {
+	case OriginWins:
+		return "origin-wins"
+	case NewestWins:
+		return "newest-wins"
+	case MajorityWins:
+		return "majority-wins"
+	}
+	return "Illegal Policy"
+}
+
+func ParsePolicy(name string) (Policy, error) {
+	for _, policy := range Policies {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return OriginWins, fmt.Errorf("unknown policy %q", name)
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Plan is the serializable form of file commands. Every operation records
+// what its source is expected to be, so the plan can be verified before
+// it is applied.
+type Plan struct {
+	Roots      []Root      `json:"roots"`
+	Operations []Operation `json:"operations"`
+}
+
+type Operation struct {
+	Op      string    `json:"op"`
+	From    PlanId    `json:"from"`
+	To      []PlanId  `json:"to,omitempty"`
+	Hash    Hash      `json:"hash,omitempty"`
+	Size    uint64    `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Files are the files under a moved folder.
+	Files []PlanFile `json:"files,omitempty"`
+}
+
+// PlanFile is a file under a moved folder as the plan expects it.
+type PlanFile struct {
+	Name    string    `json:"name"`
+	Hash    Hash      `json:"hash,omitempty"`
+	Size    uint64    `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+type PlanId struct {
+	Root Root   `json:"root"`
+	Name string `json:"name"`
+}
+
+func (id PlanId) Id() Id {
+	return Id{Root: id.Root, Name: Path(id.Name).ParentName()}
+}
+
+func planId(id Id) PlanId {
+	return PlanId{Root: id.Root, Name: id.Name.String()}
+}
+
+// NewOperation converts the command; source is the file it reads, if any.
+func NewOperation(cmd FileCommand, source *File) Operation {
+	var op Operation
+	switch cmd := cmd.(type) {
+	case DeleteFile:
+		op = Operation{Op: "delete", From: planId(cmd.Id), Hash: cmd.Hash}
+
+	case RenameFile:
+		op = Operation{Op: "rename", From: planId(cmd.From), To: []PlanId{planId(Id{Root: cmd.From.Root, Name: cmd.To})}, Hash: cmd.Hash}
+
+	case RenameFolder:
+		op = Operation{Op: "move", From: PlanId{Root: cmd.Root, Name: cmd.From.String()}, To: []PlanId{{Root: cmd.Root, Name: cmd.To.String()}}}
+
+	case CopyFile:
+		op = Operation{Op: "copy", From: planId(cmd.From), Hash: cmd.Hash}
+		for _, to := range cmd.To {
+			op.To = append(op.To, planId(to))
+		}
+	}
+	if source != nil {
+		op.Size = source.Size
+		op.ModTime = source.ModTime
+	}
+	return op
+}
+
+func (op Operation) Command() (FileCommand, error) {
+	switch op.Op {
+	case "delete":
+		return DeleteFile{Hash: op.Hash, Id: op.From.Id()}, nil
+
+	case "rename":
+		if len(op.To) != 1 || op.To[0].Root != op.From.Root {
+			return nil, fmt.Errorf("rename of %q needs one target in the same root", op.From.Name)
+		}
+		return RenameFile{Hash: op.Hash, From: op.From.Id(), To: op.To[0].Id().Name}, nil
+
+	case "move":
+		if len(op.To) != 1 || op.To[0].Root != op.From.Root {
+			return nil, fmt.Errorf("move of %q needs one target in the same root", op.From.Name)
+		}
+		return RenameFolder{Root: op.From.Root, From: Path(op.From.Name), To: Path(op.To[0].Name)}, nil
+
+	case "copy":
+		if len(op.To) == 0 {
+			return nil, fmt.Errorf("copy of %q has no targets", op.From.Name)
+		}
+		cmd := CopyFile{Hash: op.Hash, From: op.From.Id()}
+		for _, to := range op.To {
+			cmd.To = append(cmd.To, to.Id())
+		}
+		return cmd, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
@@ -175,7 +175,8 @@ func (device *tcellRenderer) handleKeyEvent(key *tcell.EventKey) {
 	case "Enter":
 		device.controllerEvents.Push(m.Open{})
 
-	// case "Esc":
+	case "Esc":
+		device.controllerEvents.Push(m.Cancel{})
 
 	case "Ctrl+F":
 		device.controllerEvents.Push(m.RevealInFinder{})
@@ -210,15 +211,42 @@ func (device *tcellRenderer) handleKeyEvent(key *tcell.EventKey) {
 	case "Ctrl+A":
 		device.controllerEvents.Push(m.ResolveAll{})
 
-	// case "Ctrl+A":
-	// 	device.controllerEvents.Push(m.KeepAll{})
+	case "Ctrl+K":
+		device.controllerEvents.Push(m.KeepAll{})
+
+	case "Ctrl+P":
+		device.controllerEvents.Push(m.CyclePolicy{})
+
+	case "Ctrl+D":
+		device.controllerEvents.Push(m.ToggleDryRun{})
+
+	case "Ctrl+S":
+		device.controllerEvents.Push(m.SavePlan{})
+
+	case "Ctrl+Z":
+		device.controllerEvents.Push(m.Undo{})
+
+	case "Ctrl+U":
+		device.controllerEvents.Push(m.Undo{Session: true})
+
+	case "Rune[ ]":
+		device.controllerEvents.Push(m.ToggleOperation{})
 
 	case "Tab":
 		device.controllerEvents.Push(m.Tab{})
 
+	case "Backtab":
+		device.controllerEvents.Push(m.Tab{Backward: true})
+
 	case "Backspace2": // Ctrl+Delete
 		device.controllerEvents.Push(m.Delete{})
 
+	case "F2":
+		device.controllerEvents.Push(m.ShowConflicts{})
+
+	case "F3":
+		device.controllerEvents.Push(m.ShowTrash{})
+
 	case "F10":
 		device.controllerEvents.Push(m.DebugPrintState{})
 	case "F12":
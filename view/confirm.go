@@ -0,0 +1,21 @@
+package view
+
+import (
+	w "arc/widgets"
+)
+
+type ConfirmDialog struct {
+	Title   string
+	Message string
+}
+
+func (d *ConfirmDialog) RootWidget() w.Widget {
+	return w.Styled(styleDefault,
+		w.Column(colConstraint,
+			screenTitle(d.Title),
+			w.Row(rowConstraint, w.Text(" "+d.Message).Flex(1)),
+			w.Spacer{},
+			hints("Enter: confirm  Esc: cancel"),
+		),
+	)
+}
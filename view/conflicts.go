@@ -0,0 +1,81 @@
+package view
+
+import (
+	m "arc/model"
+	w "arc/widgets"
+	"fmt"
+	"time"
+)
+
+type Conflict struct {
+	m.Name
+	Versions []*m.File
+}
+
+type ConflictsView struct {
+	Conflicts   []Conflict
+	SelectedIdx int
+	OffsetIdx   int
+}
+
+type ConflictDialog struct {
+	Conflict
+	Options     []string
+	SelectedIdx int
+	OffsetIdx   int
+}
+
+func (v *ConflictsView) RootWidget() w.Widget {
+	rows := []w.Widget{}
+	for _, conflict := range v.Conflicts {
+		rows = append(rows, w.Row(rowConstraint,
+			w.Text(" "+conflict.Name.String()).Width(20).Flex(1),
+			w.Text(fmt.Sprintf("%3d versions ", len(conflict.Versions))),
+		))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, w.Text(" No conflicts").Flex(1))
+	}
+	return w.Styled(styleDefault,
+		w.Column(colConstraint,
+			screenTitle(fmt.Sprintf("Conflicts: %d", len(v.Conflicts))),
+			w.Styled(styleArchiveHeader, w.Row(rowConstraint,
+				w.Text(" Document").Width(20).Flex(1),
+				w.Text(" Versions "),
+			)),
+			list(&v.OffsetIdx, v.SelectedIdx, rows),
+			hints("Enter: resolve  Esc: back"),
+		),
+	)
+}
+
+func (d *ConflictDialog) RootWidget() w.Widget {
+	versions := []w.Widget{}
+	for _, version := range d.Versions {
+		versions = append(versions, w.Row(rowConstraint,
+			w.Text(" "+version.Root.String()).Width(20).Flex(1),
+			w.Text("  "+version.ModTime.Format(time.DateTime)),
+			w.Text("  "+formatSize(version.Size)).Width(20),
+			w.Text("  "+version.Hash.String()).Width(20).Flex(1),
+		))
+	}
+	options := []w.Widget{}
+	for _, option := range d.Options {
+		options = append(options, w.Text(" "+option).Flex(1))
+	}
+	return w.Styled(styleDefault,
+		w.Column(colConstraint,
+			screenTitle("Conflict: "+d.Name.String()),
+			w.Styled(styleArchiveHeader, w.Row(rowConstraint,
+				w.Text(" Archive").Width(20).Flex(1),
+				w.Text("  Date Modified      "),
+				w.Text(fmt.Sprintf("%20s", "Size ")),
+				w.Text("  Hash").Width(20).Flex(1),
+			)),
+			w.Column(w.Constraint{Size: w.Size{Width: 0, Height: len(versions)}, Flex: w.Flex{X: 1, Y: 0}}, versions...),
+			w.Styled(styleArchiveHeader, w.Row(rowConstraint, w.Text(" Resolution").Flex(1))),
+			list(&d.OffsetIdx, d.SelectedIdx, options),
+			hints("Enter: apply  Esc: cancel"),
+		),
+	)
+}
@@ -0,0 +1,48 @@
+package view
+
+import (
+	m "arc/model"
+	w "arc/widgets"
+)
+
+var styleSelected = w.Style{FG: 226, BG: 17, Flags: w.Reverse}
+
+// list renders rows scrolled so that the selected row stays visible.
+func list(offsetIdx *int, selectedIdx int, rows []w.Widget) w.Widget {
+	return w.Scroll(m.Scroll{}, colConstraint,
+		func(size w.Size) w.Widget {
+			if *offsetIdx > selectedIdx {
+				*offsetIdx = selectedIdx
+			}
+			if *offsetIdx < selectedIdx+1-size.Height {
+				*offsetIdx = selectedIdx + 1 - size.Height
+			}
+			if *offsetIdx < 0 {
+				*offsetIdx = 0
+			}
+			visible := []w.Widget{}
+			for i, row := range rows[*offsetIdx:] {
+				if i >= size.Height {
+					break
+				}
+				if *offsetIdx+i == selectedIdx {
+					row = w.Styled(styleSelected, row)
+				}
+				visible = append(visible, row)
+			}
+			visible = append(visible, w.Spacer{})
+			return w.Column(colConstraint, visible...)
+		},
+	)
+}
+
+func screenTitle(title string) w.Widget {
+	return w.Row(rowConstraint,
+		w.Styled(styleAppTitle, w.Text(" "+title)), w.Text(" "),
+		w.Styled(styleArchive, w.Text("").Flex(1)),
+	)
+}
+
+func hints(text string) w.Widget {
+	return w.Styled(styleStatusLine, w.Row(rowConstraint, w.Text(" "+text).Flex(1)))
+}
@@ -0,0 +1,81 @@
+package view
+
+import (
+	m "arc/model"
+	w "arc/widgets"
+	"fmt"
+)
+
+type PlanView struct {
+	Operations  []Operation
+	Copies      []RootSize
+	Deletes     int
+	DeleteSize  uint64
+	SelectedIdx int
+	OffsetIdx   int
+	SavedPath   string
+}
+
+type Operation struct {
+	Text    string
+	Size    uint64
+	Dropped bool
+	Skipped bool
+}
+
+type RootSize struct {
+	Root m.Root
+	Size uint64
+}
+
+func (v *PlanView) RootWidget() w.Widget {
+	rows := []w.Widget{}
+	for _, operation := range v.Operations {
+		mark := "[x]"
+		if operation.Dropped {
+			mark = "[ ]"
+		} else if operation.Skipped {
+			mark = "[-]"
+		}
+		size := ""
+		if operation.Size > 0 {
+			size = formatSize(operation.Size)
+		}
+		rows = append(rows, w.Row(rowConstraint,
+			w.Text(" "+mark+" "),
+			w.Text(operation.Text).Width(20).Flex(1),
+			w.Text(fmt.Sprintf("%20s ", size)),
+		))
+	}
+	summary := []w.Widget{}
+	for _, copy := range v.Copies {
+		summary = append(summary, w.Row(rowConstraint,
+			w.Text(" Copy to "+copy.Root.String()).Width(20).Flex(1),
+			w.Text(fmt.Sprintf("%20s ", formatSize(copy.Size))),
+		))
+	}
+	summary = append(summary, w.Row(rowConstraint,
+		w.Text(fmt.Sprintf(" Delete %d files", v.Deletes)).Width(20).Flex(1),
+		w.Text(fmt.Sprintf("%20s ", formatSize(v.DeleteSize))),
+	))
+	return w.Styled(styleDefault,
+		w.Column(colConstraint,
+			screenTitle(fmt.Sprintf("Plan: %d operations", len(v.Operations))),
+			w.Styled(styleArchiveHeader, w.Row(rowConstraint,
+				w.Text(" Operation").Width(20).Flex(1),
+				w.Text(fmt.Sprintf("%20s ", "Size")),
+			)),
+			list(&v.OffsetIdx, v.SelectedIdx, rows),
+			w.Styled(styleArchiveHeader, w.Row(rowConstraint, w.Text(" Summary").Flex(1))),
+			w.Column(w.Constraint{Size: w.Size{Width: 0, Height: len(summary)}, Flex: w.Flex{X: 1, Y: 0}}, summary...),
+			v.hints(),
+		),
+	)
+}
+
+func (v *PlanView) hints() w.Widget {
+	if v.SavedPath != "" {
+		return hints("Saved to " + v.SavedPath + "  Enter: apply  Esc: discard")
+	}
+	return hints("Space: drop/keep  Ctrl+S: save  Enter: apply  Esc: discard")
+}
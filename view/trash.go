@@ -0,0 +1,44 @@
+package view
+
+import (
+	m "arc/model"
+	w "arc/widgets"
+	"fmt"
+	"time"
+)
+
+type TrashView struct {
+	Files       []m.TrashedFile
+	SelectedIdx int
+	OffsetIdx   int
+}
+
+func (v *TrashView) RootWidget() w.Widget {
+	rows := []w.Widget{}
+	size := uint64(0)
+	for _, file := range v.Files {
+		size += file.Size
+		rows = append(rows, w.Row(rowConstraint,
+			w.Text(" "+file.Root.String()).Width(20).Flex(1),
+			w.Text(" "+file.Name.String()).Width(20).Flex(2),
+			w.Text("  "+file.Deleted.Local().Format(time.DateTime)),
+			w.Text(fmt.Sprintf("%20s", formatSize(file.Size)+" ")),
+		))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, w.Text(" Trash is empty").Flex(1))
+	}
+	return w.Styled(styleDefault,
+		w.Column(colConstraint,
+			screenTitle(fmt.Sprintf("Trash: %d files, %s", len(v.Files), formatSize(size))),
+			w.Styled(styleArchiveHeader, w.Row(rowConstraint,
+				w.Text(" Archive").Width(20).Flex(1),
+				w.Text(" Document").Width(20).Flex(2),
+				w.Text("  Date Deleted       "),
+				w.Text(fmt.Sprintf("%20s", "Size ")),
+			)),
+			list(&v.OffsetIdx, v.SelectedIdx, rows),
+			hints("Enter: restore  Ctrl+Del: purge this and older  Esc: back"),
+		),
+	)
+}
@@ -12,6 +12,7 @@ import (
 
 type View struct {
 	Archive       m.Root
+	Label         string
 	Path          m.Path
 	Entries       []Entry
 	SelectedBase  m.Base
@@ -20,6 +21,12 @@ type View struct {
 	Progress      *Progress
 	SortColumn    m.SortColumn
 	SortAscending bool
+	Policy        m.Policy
+	Reason        string
+	Conflicts     int
+	CopyIdx       int
+	Copies        int
+	DryRun        bool
 }
 
 type Entry struct {
@@ -60,15 +67,30 @@ func (a *View) RootWidget() w.Widget {
 			a.title(),
 			a.folderWidget(),
 			a.progressWidget(),
+			a.policyWidget(),
 		),
 	)
 }
 
 func (a *View) title() w.Widget {
-	return w.Row(rowConstraint,
+	archive := a.Archive.String()
+	if a.Label != "" {
+		archive = fmt.Sprintf("%s [%s]", archive, a.Label)
+	}
+	widgets := []w.Widget{
 		w.Styled(styleAppTitle, w.Text(" Archive")), w.Text(" "),
-		w.Styled(styleArchive, w.Text(a.Archive.String()).Flex(1)),
-	)
+		w.Styled(styleArchive, w.Text(archive).Flex(1)),
+	}
+	if a.Copies > 1 {
+		widgets = append(widgets, w.Styled(styleArchive, w.Text(fmt.Sprintf(" copy %d of %d ", a.CopyIdx+1, a.Copies))))
+	}
+	if a.Conflicts > 0 {
+		widgets = append(widgets, w.Styled(styleArchive, w.Text(fmt.Sprintf(" Conflicts: %d ", a.Conflicts))))
+	}
+	if a.DryRun {
+		widgets = append(widgets, w.Styled(styleArchive, w.Text(" Dry Run ")))
+	}
+	return w.Row(rowConstraint, widgets...)
 }
 
 func (v *View) folderWidget() w.Widget {
@@ -76,7 +98,7 @@ func (v *View) folderWidget() w.Widget {
 		v.breadcrumbs(),
 		w.Styled(styleArchiveHeader,
 			w.Row(rowConstraint,
-				w.Text(" Status").Width(13),
+				w.Text(" Status").Width(17),
 				w.MouseTarget(m.SortByName, w.Text(" Document"+v.sortIndicator(m.SortByName)).Width(20).Flex(1)),
 				w.MouseTarget(m.SortByTime, w.Text("  Date Modified"+v.sortIndicator(m.SortByTime)).Width(19)),
 				w.MouseTarget(m.SortBySize, w.Text(fmt.Sprintf("%22s", "Size"+v.sortIndicator(m.SortBySize)+" "))),
@@ -131,20 +153,27 @@ func state(entry Entry) w.Widget {
 	switch entry.State {
 	case m.Hashing, m.Copying:
 		value := float64(entry.Progress) / float64(entry.Size)
-		return w.Styled(styleProgressBar, w.ProgressBar(value).Width(10).Flex(0))
+		return w.Styled(styleProgressBar, w.ProgressBar(value).Width(14).Flex(0))
 	case m.Pending:
-		return w.Text("Pending").Width(10)
+		return w.Text("Pending").Width(14)
+	case m.Unique:
+		return w.Text("Unique").Width(14)
 	case m.Divergent:
 		break
 	default:
-		return w.Text("").Width(10)
+		return w.Text("").Width(14)
+	}
+
+	if entry.Kind == Folder {
+		return w.Text("Divergent").Width(14)
 	}
 
 	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%-10s", entry.Divergence)
 	for _, count := range entry.Counts {
 		fmt.Fprintf(buf, "%c", countRune(count))
 	}
-	return w.Text(buf.String()).Width(10)
+	return w.Text(buf.String()).Width(14)
 }
 
 func countRune(count int) rune {
@@ -198,6 +227,16 @@ func (a *View) progressWidget() w.Widget {
 	))
 }
 
+func (v *View) policyWidget() w.Widget {
+	widgets := []w.Widget{w.Text(" Policy: " + v.Policy.String())}
+	if v.Reason != "" {
+		widgets = append(widgets, w.Text(" — "+v.Reason).Flex(1))
+	} else {
+		widgets = append(widgets, w.Spacer{})
+	}
+	return w.Styled(styleStatusLine, w.Row(rowConstraint, widgets...))
+}
+
 func formatSize(size uint64) string {
 	str := fmt.Sprintf("%13d ", size)
 	slice := []string{str[:1], str[1:4], str[4:7], str[7:10]}
@@ -237,6 +276,8 @@ func (a *View) statusColor(file Entry) (color byte) {
 		return 248
 	case m.Pending, m.Copying:
 		return 214
+	case m.Unique:
+		return 177
 	case m.Divergent:
 		return 196
 	}